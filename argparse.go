@@ -2,13 +2,15 @@
 package argparse
 
 import (
-	"errors"
 	"fmt"
+	"io"
 	"os"
-	"strings"
+	"reflect"
+	"text/template"
 )
 
-// DisableDescription can be assigned as a command or arguments description to hide it from the Usage output
+// DisableDescription can be assigned as a command or arguments description to hide it from the Usage output.
+// Prefer Command.Hidden and Options.Hidden, which allow hidden items to keep their description.
 const DisableDescription = "DISABLEDDESCRIPTIONWILLNOTSHOWUP"
 
 // Command is a basic type for this package. It represents top level Parser as well as any commands and sub-commands
@@ -24,6 +26,32 @@ type Command struct {
 	parent      *Command
 	HelpFunc    func(c *Command, msg interface{}) string
 	exitOnHelp  bool
+	// Hidden hides Command from the help message of its parent, unless help is invoked with --help-all
+	Hidden bool
+
+	helpTemplate  *template.Template
+	helpWidth     int
+	epilog        string
+	examples      []Example
+	action        func(c *Command, args *[]string) error
+	deferErrors   bool
+	defErrors     []error
+	collectErrors bool
+	parseErrors   []error
+	invalidArgs   map[*arg]bool
+	lastErr       error
+	lastErrIndex  int
+	dry           bool
+	consumed      []bool
+	ordering      Ordering
+	passThrough   *[]string
+	builtin       string
+	handler       func(c *Command) error
+	version       string
+	argv          []string
+	output        io.Writer
+	colorTheme    *ColorTheme
+	forceColor    bool
 }
 
 // GetName exposes Command's name field
@@ -33,9 +61,43 @@ func (o Command) GetName() string {
 
 // GetDescription exposes Command's description field
 func (o Command) GetDescription() string {
+	if o.description == DisableDescription {
+		return ""
+	}
 	return o.description
 }
 
+// GetEpilog exposes Command's epilog field
+func (o Command) GetEpilog() string {
+	return o.epilog
+}
+
+// SetEpilog sets a text that is displayed at the end of the help message of this Command.
+// Epilog is displayed as is, it is not wrapped to the help width.
+func (o *Command) SetEpilog(epilog string) {
+	o.epilog = epilog
+}
+
+// Example is a sample invocation of a Command displayed in its help message
+type Example struct {
+	// Description explains what the example does, can be empty
+	Description string `json:"description,omitempty"`
+	// Command is the full command line of the example starting with the program name,
+	// e.g. "prog deploy --env staging"
+	Command string `json:"command"`
+}
+
+// GetExamples exposes Command's examples field
+func (o Command) GetExamples() []Example {
+	return o.examples
+}
+
+// AddExample adds a sample invocation to the Examples section of the help message of this Command.
+// Description and command are displayed as is, they are not wrapped to the help width.
+func (o *Command) AddExample(description string, command string) {
+	o.examples = append(o.examples, Example{Description: description, Command: command})
+}
+
 // GetArgs exposes Command's args field
 func (o Command) GetArgs() (args []Arg) {
 	for _, arg := range o.args {
@@ -44,6 +106,64 @@ func (o Command) GetArgs() (args []Arg) {
 	return
 }
 
+// GetArg returns argument of this Command or any of its parents with provided long name,
+// or nil if there is no such argument
+func (o *Command) GetArg(lname string) Arg {
+	for current := o; current != nil; current = current.parent {
+		for _, a := range current.args {
+			if a.lname == lname {
+				return a
+			}
+		}
+	}
+	return nil
+}
+
+// SetValue sets argument with long name lname (looked up the same way as GetArg) as if values were given to it
+// on command line and records source of the values. It allows to merge values from environment variables
+// or configuration files after Parse: each call replaces value set by default or by previous call, so sources
+// can be applied from the lowest priority to the highest. Arguments provided on command line are not changed
+// and an error is returned for them.
+// Flags take no value or a single boolean value, other arguments take each of values as a separate occurrence.
+func (o *Command) SetValue(lname string, values []string, source Source) error {
+	a, ok := o.GetArg(lname).(*arg)
+	if !ok {
+		return fmt.Errorf("unknown argument [%s]", lname)
+	}
+	if a.source.Kind == SourceCommandLine {
+		return fmt.Errorf("[%s] was provided on command line", a.name())
+	}
+	switch result := reflect.ValueOf(a.result).Elem(); result.Kind() {
+	case reflect.Map:
+		result.Set(reflect.MakeMap(result.Type()))
+	default:
+		result.Set(reflect.Zero(result.Type()))
+	}
+	if a.nullable != nil {
+		nullable := reflect.ValueOf(a.nullable).Elem()
+		nullable.Set(reflect.Zero(nullable.Type()))
+	}
+	a.parsed = false
+	a.negated = false
+	a.source = Source{}
+	if a.size == 1 {
+		if err := a.parse(values, 1); err != nil {
+			return err
+		}
+	} else {
+		if len(values) == 0 {
+			return fmt.Errorf("not enough arguments for %s", a.name())
+		}
+		for _, value := range values {
+			if err := a.parse([]string{value}, 1); err != nil {
+				return err
+			}
+		}
+	}
+	a.source = source
+	return nil
+}
+
 // GetCommands exposes Command's commands field
 func (o Command) GetCommands() []*Command {
 	return o.commands
@@ -54,6 +174,18 @@ func (o Command) GetParent() *Command {
 	return o.parent
 }
 
+// GetPath returns names of commands from the top level Parser to this Command
+func (o *Command) GetPath() []string {
+	path := make([]string, 0)
+	o.getPrecedingCommands(&path, new([]*arg))
+	return path
+}
+
+// GetExitOnHelp exposes Command's exitOnHelp field
+func (o Command) GetExitOnHelp() bool {
+	return o.exitOnHelp
+}
+
 // Help calls the overriddable Command.HelpFunc on itself,
 // called when the help argument strings are passed via CLI
 func (o *Command) Help(msg interface{}) string {
@@ -86,16 +218,62 @@ type Parser struct {
 // which will be the output of `Parser.Parse` method.
 //
 // Options.Help - A help message to be displayed in Usage output. Can be of any length as the message will be
-// formatted to fit max screen width of 100 characters.
+// formatted to fit help width (see Command.SetHelpWidth).
+//
+// Options.Negatable - for Flag, adds --no-<long name> argument, which sets flag to false. Useful with flags that
+// default to true. Regardless of this option Flag accepts explicit value as in --flag=false
+// (true, false, 1, 0, yes and no are allowed).
+//
+// Options.Hidden - hides argument from Usage output, unless help is invoked with --help-all (see Parser.EnableHelpAll).
 //
 // Options.Default - A default value for an argument. This value will be assigned to the argument at the end of parsing
 // in case if this argument was not supplied on command line. File default value is a string which it will be open with
 // provided options. In case if provided value type does not match expected, the error will be returned on run-time.
 type Options struct {
-	Required bool
-	Validate func(args []string) error
-	Help     string
-	Default  interface{}
+	Required      bool
+	Validate      func(args []string) error
+	Help          string
+	Default       interface{}
+	Hidden        bool
+	Negatable     bool
+	DuplicateKeys DuplicateKeyPolicy
+}
+
+// DuplicateKeyPolicy defines what map arguments (such as StringMap) do when the same key is provided more than once
+type DuplicateKeyPolicy int
+
+const (
+	// DuplicateKeyOverwrite keeps the value provided last
+	DuplicateKeyOverwrite DuplicateKeyPolicy = iota
+	// DuplicateKeyKeepFirst keeps the value provided first
+	DuplicateKeyKeepFirst
+	// DuplicateKeyError fails parsing
+	DuplicateKeyError
+)
+
+var duplicateKeyPolicyNames = map[DuplicateKeyPolicy]string{
+	DuplicateKeyOverwrite: "overwrite",
+	DuplicateKeyKeepFirst: "keepFirst",
+	DuplicateKeyError:     "error",
+}
+
+// MarshalText encodes policy as its name ("overwrite", "keepFirst" or "error"), as it appears in JSON schema
+func (p DuplicateKeyPolicy) MarshalText() ([]byte, error) {
+	if name, ok := duplicateKeyPolicyNames[p]; ok {
+		return []byte(name), nil
+	}
+	return nil, fmt.Errorf("unknown duplicate key policy %d", int(p))
+}
+
+// UnmarshalText decodes policy from its name, see DuplicateKeyPolicy.MarshalText
+func (p *DuplicateKeyPolicy) UnmarshalText(text []byte) error {
+	for policy, name := range duplicateKeyPolicyNames {
+		if name == string(text) {
+			*p = policy
+			return nil
+		}
+	}
+	return fmt.Errorf("unknown duplicate key policy %q", text)
 }
 
 // NewParser creates new Parser object that will allow to add arguments for parsing
@@ -151,6 +329,83 @@ func (o *Parser) DisableHelp() {
 	}
 }
 
+// DeferErrors switches Parser into the mode in which argument definition errors (such as duplicate names)
+// do not panic. Instead arguments that failed to be added are ignored and errors are collected,
+// to be reported by Parser.Validate and Parser.Parse. Useful when arguments are defined dynamically.
+func (o *Parser) DeferErrors(b bool) {
+	o.deferErrors = b
+}
+
+// Validate returns DefinitionErrors listing all argument definition errors collected in deferred errors mode
+// (see Parser.DeferErrors), or nil if there were none.
+func (o *Parser) Validate() error {
+	if len(o.defErrors) == 0 {
+		return nil
+	}
+	errs := make(DefinitionErrors, len(o.defErrors))
+	copy(errs, o.defErrors)
+	return errs
+}
+
+// CollectErrors switches Parser into the mode in which parsing does not stop at the first error.
+// Instead all missing, invalid and unknown arguments are collected and Parser.Parse returns them as ParseErrors.
+func (o *Parser) CollectErrors(b bool) {
+	o.collectErrors = b
+}
+
+// ErrorIndex returns position in the slice passed to Parser.Parse of the token that caused err returned by Parse,
+// or -1 if err is not about a single token (such as missing argument). See also InvalidArgumentError
+// and UnknownArgumentError, which carry position of the token themselves.
+func (o *Parser) ErrorIndex(err error) int {
+	if indexes := o.errorIndexes(err); len(indexes) == 1 {
+		return indexes[0]
+	}
+	return -1
+}
+
+// EnableHelpAll adds --help-all argument, which prints help message including hidden commands and arguments.
+// The argument itself is hidden.
+func (o *Parser) EnableHelpAll() {
+	a := &arg{
+		result: &helpAll{},
+		lname:  "help-all",
+		size:   1,
+		opts:   &Options{Help: "Print help information including hidden commands and arguments", Hidden: true},
+		unique: true,
+	}
+
+	o.addArgOrFail("help-all", a)
+}
+
+// EnableHelpCommand adds "help" command, which prints help message of the command specified by names following it,
+// e.g. `prog help deploy staging` prints the same message as `prog deploy staging --help`.
+// Without names it prints help message of the Parser. Returns the added command.
+func (o *Parser) EnableHelpCommand() *Command {
+	c := o.NewCommand("help", "Print help information for a command")
+	c.action = (*Command).parseHelpCommand
+	c.builtin = "help"
+	return c
+}
+
+// EnablePassThrough makes this Command capture all tokens following its name verbatim, once it is selected.
+// Captured tokens are neither parsed as arguments of this Command or its parents, nor as sub-commands,
+// so that they can be passed to another program, as in `prog run tool --help`. Arguments of this Command
+// and its parents can only take their default values. Returns a pointer to the slice of captured tokens.
+func (o *Command) EnablePassThrough() *[]string {
+	result := make([]string, 0)
+	o.passThrough = &result
+	return &result
+}
+
+// GetPassThrough returns tokens captured by Command with pass-through enabled (see Command.EnablePassThrough),
+// or nil if pass-through is not enabled
+func (o *Command) GetPassThrough() []string {
+	if o.passThrough == nil {
+		return nil
+	}
+	return *o.passThrough
+}
+
 // ExitOnHelp sets the exitOnHelp variable of Parser
 func (o *Command) ExitOnHelp(b bool) {
 	o.exitOnHelp = b
@@ -184,9 +439,7 @@ func (o *Command) Flag(short string, long string, opts *Options) *bool {
 		unique: true,
 	}
 
-	if err := o.addArg(a); err != nil {
-		panic(fmt.Errorf("unable to add Flag: %s", err.Error()))
-	}
+	o.addArgOrFail("Flag", a)
 
 	return &result
 }
@@ -210,9 +463,7 @@ func (o *Command) FlagCounter(short string, long string, opts *Options) *int {
 		unique: false,
 	}
 
-	if err := o.addArg(a); err != nil {
-		panic(fmt.Errorf("unable to add FlagCounter: %s", err.Error()))
-	}
+	o.addArgOrFail("FlagCounter", a)
 
 	return &result
 }
@@ -232,9 +483,7 @@ func (o *Command) String(short string, long string, opts *Options) *string {
 		unique: true,
 	}
 
-	if err := o.addArg(a); err != nil {
-		panic(fmt.Errorf("unable to add String: %s", err.Error()))
-	}
+	o.addArgOrFail("String", a)
 
 	return &result
 }
@@ -255,9 +504,7 @@ func (o *Command) Int(short string, long string, opts *Options) *int {
 		unique: true,
 	}
 
-	if err := o.addArg(a); err != nil {
-		panic(fmt.Errorf("unable to add Int: %s", err.Error()))
-	}
+	o.addArgOrFail("Int", a)
 
 	return &result
 }
@@ -278,9 +525,7 @@ func (o *Command) Float(short string, long string, opts *Options) *float64 {
 		unique: true,
 	}
 
-	if err := o.addArg(a); err != nil {
-		panic(fmt.Errorf("unable to add Float: %s", err.Error()))
-	}
+	o.addArgOrFail("Float", a)
 
 	return &result
 }
@@ -306,9 +551,7 @@ func (o *Command) File(short string, long string, flag int, perm os.FileMode, op
 		filePerm: perm,
 	}
 
-	if err := o.addArg(a); err != nil {
-		panic(fmt.Errorf("unable to add File: %s", err.Error()))
-	}
+	o.addArgOrFail("File", a)
 
 	return &result
 }
@@ -337,9 +580,7 @@ func (o *Command) StringList(short string, long string, opts *Options) *[]string
 		unique: false,
 	}
 
-	if err := o.addArg(a); err != nil {
-		panic(fmt.Errorf("unable to add StringList: %s", err.Error()))
-	}
+	o.addArgOrFail("StringList", a)
 
 	return &result
 }
@@ -360,9 +601,7 @@ func (o *Command) IntList(short string, long string, opts *Options) *[]int {
 		unique: false,
 	}
 
-	if err := o.addArg(a); err != nil {
-		panic(fmt.Errorf("unable to add IntList: %s", err.Error()))
-	}
+	o.addArgOrFail("IntList", a)
 
 	return &result
 }
@@ -383,9 +622,7 @@ func (o *Command) FloatList(short string, long string, opts *Options) *[]float64
 		unique: false,
 	}
 
-	if err := o.addArg(a); err != nil {
-		panic(fmt.Errorf("unable to add FloatList: %s", err.Error()))
-	}
+	o.addArgOrFail("FloatList", a)
 
 	return &result
 }
@@ -408,10 +645,70 @@ func (o *Command) FileList(short string, long string, flag int, perm os.FileMode
 		filePerm: perm,
 	}
 
-	if err := o.addArg(a); err != nil {
-		panic(fmt.Errorf("unable to add FileList: %s", err.Error()))
+	o.addArgOrFail("FileList", a)
+
+	return &result
+}
+
+// StringMap creates new string map argument. This is the argument that is allowed to be present multiple times
+// on CLI, each time followed by key=value pair, such as --label env=prod. The value is everything after the first
+// equals char. All pairs are collected into the map, keys provided more than once are handled according
+// to Options.DuplicateKeys. If no argument provided, then the map is empty. Takes same parameters as String
+// Returns a pointer the map of strings.
+func (o *Command) StringMap(short string, long string, opts *Options) *map[string]string {
+	result := make(map[string]string)
+
+	a := &arg{
+		result: &result,
+		sname:  short,
+		lname:  long,
+		size:   2,
+		opts:   opts,
+		unique: false,
 	}
 
+	o.addArgOrFail("StringMap", a)
+
+	return &result
+}
+
+// IntMap creates new integer map argument, which collects key=value pairs with integer values,
+// see StringMap. Takes same parameters as String
+// Returns a pointer the map of integers.
+func (o *Command) IntMap(short string, long string, opts *Options) *map[string]int {
+	result := make(map[string]int)
+
+	a := &arg{
+		result: &result,
+		sname:  short,
+		lname:  long,
+		size:   2,
+		opts:   opts,
+		unique: false,
+	}
+
+	o.addArgOrFail("IntMap", a)
+
+	return &result
+}
+
+// FloatMap creates new float map argument, which collects key=value pairs with floating point values,
+// see StringMap. Takes same parameters as String
+// Returns a pointer the map of floats.
+func (o *Command) FloatMap(short string, long string, opts *Options) *map[string]float64 {
+	result := make(map[string]float64)
+
+	a := &arg{
+		result: &result,
+		sname:  short,
+		lname:  long,
+		size:   2,
+		opts:   opts,
+		unique: false,
+	}
+
+	o.addArgOrFail("FloatMap", a)
+
 	return &result
 }
 
@@ -434,27 +731,106 @@ func (o *Command) Selector(short string, long string, options []string, opts *Op
 		selector: &options,
 	}
 
-	if err := o.addArg(a); err != nil {
-		panic(fmt.Errorf("unable to add Selector: %s", err.Error()))
+	o.addArgOrFail("Selector", a)
+
+	return &result
+}
+
+// NullableFlag creates new flag argument same as Flag, but returns pointer to pointer to boolean.
+// The pointer to boolean stays nil unless argument is provided on command line, which allows to tell
+// `--flag=false` from absence of the flag. Options.Default is not applied to nullable arguments.
+func (o *Command) NullableFlag(short string, long string, opts *Options) **bool {
+	var value bool
+	var result *bool
+
+	a := &arg{
+		result:   &value,
+		nullable: &result,
+		sname:    short,
+		lname:    long,
+		size:     1,
+		opts:     opts,
+		unique:   true,
+	}
+
+	o.addArgOrFail("NullableFlag", a)
+
+	return &result
+}
+
+// NullableString creates new string argument same as String, but returns pointer to pointer to string.
+// The pointer to string stays nil unless argument is provided on command line.
+// Options.Default is not applied to nullable arguments.
+func (o *Command) NullableString(short string, long string, opts *Options) **string {
+	var value string
+	var result *string
+
+	a := &arg{
+		result:   &value,
+		nullable: &result,
+		sname:    short,
+		lname:    long,
+		size:     2,
+		opts:     opts,
+		unique:   true,
 	}
 
+	o.addArgOrFail("NullableString", a)
+
+	return &result
+}
+
+// NullableInt creates new int argument same as Int, but returns pointer to pointer to int.
+// The pointer to int stays nil unless argument is provided on command line, which allows to tell
+// `--int 0` from absence of the argument. Options.Default is not applied to nullable arguments.
+func (o *Command) NullableInt(short string, long string, opts *Options) **int {
+	var value int
+	var result *int
+
+	a := &arg{
+		result:   &value,
+		nullable: &result,
+		sname:    short,
+		lname:    long,
+		size:     2,
+		opts:     opts,
+		unique:   true,
+	}
+
+	o.addArgOrFail("NullableInt", a)
+
+	return &result
+}
+
+// NullableFloat creates new float argument same as Float, but returns pointer to pointer to float64.
+// The pointer to float64 stays nil unless argument is provided on command line.
+// Options.Default is not applied to nullable arguments.
+func (o *Command) NullableFloat(short string, long string, opts *Options) **float64 {
+	var value float64
+	var result *float64
+
+	a := &arg{
+		result:   &value,
+		nullable: &result,
+		sname:    short,
+		lname:    long,
+		size:     2,
+		opts:     opts,
+		unique:   true,
+	}
+
+	o.addArgOrFail("NullableFloat", a)
+
 	return &result
 }
 
 // message2String puts msg in result string
-// done boolean indicates if result is ready to be returned
 // Accepts an interface that can be error, string or fmt.Stringer that will be prepended to a message.
 // All other interface types will be ignored
-func message2String(msg interface{}) (string, bool) {
+func message2String(msg interface{}) string {
 	var result string
 	if msg != nil {
 		switch msg.(type) {
-		case subCommandError:
-			result = fmt.Sprintf("%s\n", msg.(error).Error())
-			if msg.(subCommandError).cmd != nil {
-				result += msg.(subCommandError).cmd.Usage(nil)
-			}
-			return result, true
 		case error:
 			result = fmt.Sprintf("%s\n", msg.(error).Error())
 		case string:
@@ -463,7 +839,7 @@ func message2String(msg interface{}) (string, bool) {
 			result = fmt.Sprintf("%s\n", msg.(fmt.Stringer).String())
 		}
 	}
-	return result, false
+	return result
 }
 
 // getPrecedingCommands - collects info on command chain from root to current (o *Command) and all arguments in this chain
@@ -485,14 +861,19 @@ func (o *Command) getPrecedingCommands(chain *[]string, arguments *[]*arg) {
 	}
 }
 
-// getSubCommands - collects info on subcommands of current command
-func (o *Command) getSubCommands(chain *[]string) []Command {
+// isHidden - tells whether command must not be shown in the help message
+func (o *Command) isHidden() bool {
+	return o.Hidden || o.description == DisableDescription
+}
+
+// getSubCommands - collects info on subcommands of current command, including hidden ones if all is true
+func (o *Command) getSubCommands(chain *[]string, all bool) []Command {
 	commands := make([]Command, 0)
 	if o.commands != nil && len(o.commands) > 0 {
 		*chain = append(*chain, "<Command>")
 		for _, v := range o.commands {
 			// Skip hidden commands
-			if v.description == DisableDescription {
+			if v.isHidden() && !all {
 				continue
 			}
 			commands = append(commands, *v)
@@ -501,97 +882,6 @@ func (o *Command) getSubCommands(chain *[]string) []Command {
 	return commands
 }
 
-// precedingCommands2Result - puts info about command chain from root to current (o *Command) into result string buffer
-func (o *Command) precedingCommands2Result(result string, chain []string, arguments []*arg, maxWidth int) string {
-	leftPadding := len("usage: " + chain[0] + "")
-	// Add preceding commands
-	for _, v := range chain {
-		result = addToLastLine(result, v, maxWidth, leftPadding, true)
-	}
-	// Add arguments from this and all preceding commands
-	for _, v := range arguments {
-		// Skip arguments that are hidden
-		if v.opts.Help == DisableDescription {
-			continue
-		}
-		result = addToLastLine(result, v.usage(), maxWidth, leftPadding, true)
-	}
-	// Add program/Command description to the result
-	result = result + "\n\n" + strings.Repeat(" ", leftPadding)
-	result = addToLastLine(result, o.description, maxWidth, leftPadding, true)
-	result = result + "\n\n"
-
-	return result
-}
-
-// subCommands2Result - puts info about subcommands of current command into result string buffer
-func subCommands2Result(result string, commands []Command, maxWidth int) string {
-	// Add list of sub-commands to the result
-	if len(commands) > 0 {
-		cmdContent := "Commands:\n\n"
-		// Get biggest padding
-		var cmdPadding int
-		for _, com := range commands {
-			if com.description == DisableDescription {
-				continue
-			}
-			if len("  "+com.name+"  ") > cmdPadding {
-				cmdPadding = len("  " + com.name + "  ")
-			}
-		}
-		// Now add commands with known padding
-		for _, com := range commands {
-			if com.description == DisableDescription {
-				continue
-			}
-			cmd := "  " + com.name
-			cmd = cmd + strings.Repeat(" ", cmdPadding-len(cmd)-1)
-			cmd = addToLastLine(cmd, com.description, maxWidth, cmdPadding, true)
-			cmdContent = cmdContent + cmd + "\n"
-		}
-		result = result + cmdContent + "\n"
-	}
-	return result
-}
-
-// arguments2Result - puts info about all arguments of current command into result string buffer
-func arguments2Result(result string, arguments []*arg, maxWidth int) string {
-	if len(arguments) > 0 {
-		argContent := "Arguments:\n\n"
-		// Get biggest padding
-		var argPadding int
-		// Find biggest padding
-		for _, argument := range arguments {
-			if argument.opts.Help == DisableDescription {
-				continue
-			}
-			if len(argument.lname)+9 > argPadding {
-				argPadding = len(argument.lname) + 9
-			}
-		}
-		// Now add args with padding
-		for _, argument := range arguments {
-			if argument.opts.Help == DisableDescription {
-				continue
-			}
-			arg := "  "
-			if argument.sname != "" {
-				arg = arg + "-" + argument.sname + "  "
-			} else {
-				arg = arg + "    "
-			}
-			arg = arg + "--" + argument.lname
-			arg = arg + strings.Repeat(" ", argPadding-len(arg))
-			if argument.opts != nil && argument.opts.Help != "" {
-				arg = addToLastLine(arg, argument.getHelpMessage(), maxWidth, argPadding, true)
-			}
-			argContent = argContent + arg + "\n"
-		}
-		result = result + argContent + "\n"
-	}
-	return result
-}
-
 // Happened shows whether Command was specified on CLI arguments or not. If Command did not "happen", then
 // all its descendant commands and arguments are not parsed. Returns a boolean value.
 func (o *Command) Happened() bool {
@@ -612,32 +902,17 @@ func (o *Command) Usage(msg interface{}) string {
 		}
 	}
 
-	// Stay classy
-	maxWidth := 80
-	// List of arguments from all preceding commands
-	arguments := make([]*arg, 0)
-	// Line of commands until root
-	var chain []string
-
-	// Put message in result
-	result, done := message2String(msg)
-	if done {
-		return result
+	// Sub-command errors are displayed with usage of the command that requires sub-command
+	if e, ok := msg.(subCommandError); ok {
+		if e.cmd == nil {
+			return o.getColorTheme().paint("error", e.Error()) + "\n"
+		}
+		if e.cmd != o {
+			return e.cmd.Usage(msg)
+		}
 	}
 
-	//collect info about Preceding Commands into chain and arguments
-	o.getPrecedingCommands(&chain, &arguments)
-	// If this Command has sub-commands we need their list
-	commands := o.getSubCommands(&chain)
-
-	// Build usage description from description of preceding commands chain and each of subcommands
-	result += "usage:"
-	result = o.precedingCommands2Result(result, chain, arguments, maxWidth)
-	result = subCommands2Result(result, commands, maxWidth)
-	// Add list of arguments to the result
-	result = arguments2Result(result, arguments, maxWidth)
-
-	return result
+	return o.renderHelp(msg, false)
 }
 
 // Parse method can be applied only on Parser. It takes a slice of strings (as in os.Args)
@@ -648,19 +923,94 @@ func (o *Command) Usage(msg interface{}) string {
 // In case no error returned all arguments should be safe to use. Safety of using arguments
 // before Parse operation is complete is not guaranteed.
 func (o *Parser) Parse(args []string) error {
+	unparsed, indexes, err := o.parseKnown(args)
+	if err != nil {
+		return err
+	}
+	if o.collectErrors {
+		for i, v := range unparsed {
+			o.parseErrors = append(o.parseErrors, UnknownArgumentError{Token: v, Index: indexes[i]})
+		}
+		return o.collectedErrors()
+	}
+	if len(unparsed) > 0 {
+		return unknownArgumentsError{tokens: unparsed, indexes: indexes}
+	}
+	return nil
+}
+
+// ParseKnownArgs works same as Parse, except that tokens which are not arguments do not cause an error.
+// Instead they are returned in their original order, so that they can be passed to another program.
+// Run of short names is returned without the names that were recognized, e.g. -x for -vx if only -v is known.
+// Returns nil slice on error.
+func (o *Parser) ParseKnownArgs(args []string) ([]string, error) {
+	unparsed, _, err := o.parseKnown(args)
+	if err == nil && o.collectErrors {
+		err = o.collectedErrors()
+	}
+	if err != nil {
+		return nil, err
+	}
+	return unparsed, nil
+}
+
+// parseKnown - parses args and returns tokens that were not consumed, with their positions in args
+func (o *Parser) parseKnown(args []string) ([]string, []int, error) {
+	if err := o.Validate(); err != nil {
+		return nil, nil, err
+	}
+
 	subargs := make([]string, len(args))
 	copy(subargs, args)
+	o.argv = args
+	o.consumed = make([]bool, len(args))
+	o.parseErrors = nil
+	o.invalidArgs = make(map[*arg]bool)
+	o.lastErr = nil
 
-	result := o.parse(&subargs)
+	err := o.parse(&subargs)
 	unparsed := make([]string, 0)
-	for _, v := range subargs {
-		if v != "" {
+	indexes := make([]int, 0)
+	for i, v := range subargs {
+		if !o.isConsumed(&subargs, i) {
 			unparsed = append(unparsed, v)
+			indexes = append(indexes, o.argvIndex(&subargs, i))
 		}
 	}
-	if result == nil && len(unparsed) > 0 {
-		return errors.New("unknown arguments " + strings.Join(unparsed, " "))
+	return unparsed, indexes, err
+}
+
+// collectedErrors - returns errors collected while parsing as ParseErrors, or nil if there were none
+func (o *Parser) collectedErrors() error {
+	if len(o.parseErrors) == 0 {
+		return nil
 	}
+	return ParseErrors(o.parseErrors)
+}
 
-	return result
+// SetHandler sets function that is called by Parser.Run when this Command is selected
+func (o *Command) SetHandler(handler func(c *Command) error) {
+	o.handler = handler
+}
+
+// Run parses args same as Parse does and calls handler of the selected command (see Command.SetHandler).
+// If selected command has no handler, handler of its closest parent is called with the selected command.
+// Returns parsing error or error returned by handler.
+func (o *Parser) Run(args []string) error {
+	if err := o.Parse(args); err != nil {
+		return err
+	}
+	selected := &o.Command
+	for i := 0; i < len(selected.commands); i++ {
+		if selected.commands[i].happened {
+			selected = selected.commands[i]
+			i = -1
+		}
+	}
+	for current := selected; current != nil; current = current.parent {
+		if current.handler != nil {
+			return current.handler(selected)
+		}
+	}
+	return nil
 }
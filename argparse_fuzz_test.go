@@ -0,0 +1,175 @@
+package argparse
+
+import (
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"strings"
+	"testing"
+)
+
+// fuzzSeeds - command lines every fuzz target starts with, separated by new lines
+var fuzzSeeds = []string{
+	"prog",
+	"prog -vv=x",
+	"prog --aa=b=c",
+	"prog --define=a=b -D a=c -D=b=",
+	"prog -vqs value",
+	"prog -s=a=b -n=1",
+	"prog -vxs=out\n-n\n-1",
+	"prog --no-quiet --list a --list=b",
+	"prog cmd -c 2 --level info",
+	"prog cmd sub --aa -- -v",
+	"prog help cmd",
+	"prog\n\n-v",
+}
+
+// newFuzzParser - returns parser with all kinds of arguments and commands used by FuzzParse
+func newFuzzParser() *Parser {
+	p := NewParser("prog", "description")
+	p.ExitOnHelp(false)
+	p.SetOutput(ioutil.Discard)
+	p.FlagCounter("v", "verbose", nil)
+	p.Flag("q", "quiet", &Options{Negatable: true})
+	p.String("s", "str", nil)
+	p.Int("n", "num", &Options{Default: 3})
+	p.Float("f", "float", nil)
+	p.StringList("l", "list", nil)
+	p.String("", "aa", nil)
+	p.NullableString("", "aa=b", nil)
+	p.StringMap("D", "define", &Options{DuplicateKeys: DuplicateKeyError})
+	p.EnableHelpCommand()
+	cmd := p.NewCommand("cmd", "cmd description")
+	cmd.IntList("c", "count", nil)
+	cmd.Selector("", "level", []string{"debug", "info"}, nil)
+	sub := cmd.NewCommand("sub", "sub description")
+	sub.Flag("x", "extra", nil)
+	return p
+}
+
+// newDefinedParser - returns parser with arguments defined by bytes of defs, every three bytes define
+// type, short and long name of an argument, and whether it belongs to a command.
+// Returns nil if definitions are not valid.
+func newDefinedParser(defs []byte) *Parser {
+	p := NewParser("prog", "description")
+	p.ExitOnHelp(false)
+	p.SetOutput(ioutil.Discard)
+	p.DeferErrors(true)
+	cmd := p.NewCommand("cmd", "cmd description")
+	for i := 0; i+2 < len(defs) && i < 60; i += 3 {
+		target := &p.Command
+		if defs[i]&0x80 != 0 {
+			target = cmd
+		}
+		sname := ""
+		if defs[i+1]%5 != 0 {
+			sname = string(rune('a' + defs[i+1]%5))
+		}
+		lname := string(rune('a'+defs[i+2]%4)) + strings.Repeat("=", int(defs[i+2]/4%2))
+		switch defs[i] % 9 {
+		case 0:
+			target.Flag(sname, lname, &Options{Negatable: defs[i+2]&0x40 != 0})
+		case 1:
+			target.FlagCounter(sname, lname, nil)
+		case 2:
+			target.String(sname, lname, nil)
+		case 3:
+			target.Int(sname, lname, nil)
+		case 4:
+			target.Float(sname, lname, nil)
+		case 5:
+			target.StringList(sname, lname, nil)
+		case 6:
+			target.IntList(sname, lname, nil)
+		case 7:
+			target.Selector(sname, lname, []string{"a", "b"}, nil)
+		case 8:
+			target.NullableInt(sname, lname, nil)
+		}
+	}
+	if p.Validate() != nil {
+		return nil
+	}
+	return p
+}
+
+// parseState - returns description of parsing result: error, selected commands and values of all arguments
+func parseState(p *Parser, err error) string {
+	var state strings.Builder
+	fmt.Fprintf(&state, "error: %v\n", err)
+	var describe func(c *Command)
+	describe = func(c *Command) {
+		fmt.Fprintf(&state, "command %s happened %v\n", c.GetName(), c.Happened())
+		for _, a := range c.GetArgs() {
+			fmt.Fprintf(&state, "  %s %v %#v\n", a.GetLname(), a.GetParsed(), a.GetValue())
+		}
+		for _, sub := range c.GetCommands() {
+			describe(sub)
+		}
+	}
+	describe(&p.Command)
+	return state.String()
+}
+
+// checkParse - parses args with parsers returned by newParser and checks invariants of parsing:
+// it does not panic, every token is either consumed or reported, and the result is deterministic
+func checkParse(t *testing.T, newParser func() *Parser, args []string) {
+	p := newParser()
+	if p == nil {
+		return
+	}
+	err := p.Parse(args)
+	if err == nil {
+		for i, consumed := range p.consumed {
+			if !consumed {
+				t.Fatalf("token %d %q of %q was silently dropped", i, args[i], args)
+			}
+		}
+	}
+	state := parseState(p, err)
+	again := newParser()
+	if againState := parseState(again, again.Parse(args)); againState != state {
+		t.Fatalf("parsing %q is not deterministic:\n%s\n%s", args, state, againState)
+	}
+
+	// When parse errors are collected, every token that is not consumed is reported as unknown argument
+	collecting := newParser()
+	collecting.CollectErrors(true)
+	err = collecting.Parse(args)
+	reported := make(map[int]bool)
+	var parseErrors ParseErrors
+	if errors.As(err, &parseErrors) {
+		for _, e := range parseErrors {
+			if unknown, ok := e.(UnknownArgumentError); ok {
+				reported[unknown.Index] = true
+			}
+		}
+	}
+	for i, consumed := range collecting.consumed {
+		if !consumed && !reported[i] {
+			t.Fatalf("token %d %q of %q was neither consumed nor reported: %v", i, args[i], args, err)
+		}
+	}
+}
+
+func FuzzParse(f *testing.F) {
+	for _, seed := range fuzzSeeds {
+		f.Add(seed)
+	}
+	f.Fuzz(func(t *testing.T, line string) {
+		checkParse(t, newFuzzParser, strings.FieldsFunc(line, func(r rune) bool { return r == ' ' || r == '\n' }))
+		checkParse(t, newFuzzParser, strings.Split(line, "\n"))
+	})
+}
+
+func FuzzParseDefinitions(f *testing.F) {
+	for i, seed := range fuzzSeeds {
+		f.Add([]byte{byte(i), byte(i * 7), byte(i * 13), 0x80 | byte(i+2), 3, 6}, seed)
+	}
+	f.Fuzz(func(t *testing.T, defs []byte, line string) {
+		newParser := func() *Parser {
+			return newDefinedParser(defs)
+		}
+		checkParse(t, newParser, strings.Split(line, " "))
+	})
+}
@@ -1,15 +1,24 @@
 package argparse
 
 import (
+	"encoding/json"
 	"errors"
 	"fmt"
+	"io"
 	"os"
 	"reflect"
+	"runtime/debug"
 	"strconv"
 	"strings"
 	"testing"
 )
 
+func TestMain(m *testing.M) {
+	// Help and usage expectations are wrapped to the default width, do not let the terminal change it
+	os.Unsetenv("COLUMNS")
+	os.Exit(m.Run())
+}
+
 func TestInternalFunctionParse(t *testing.T) {
 	// common testing data
 	a := &arg{
@@ -65,27 +74,6 @@ func TestInternalFunctionParse(t *testing.T) {
 	}
 }
 
-func TestInternalFunctionCheck(t *testing.T) {
-	var resultS string
-	//test string
-	a := &arg{
-		result: &resultS,
-		sname:  "f",
-		lname:  "flag",
-		size:   0,
-		opts:   nil,
-		unique: true,
-	}
-
-	srgString := "-f"
-	failureMessage := "Argument's size < 1 is not allowed"
-
-	if _, err := a.check(srgString); err == nil || err.Error() != failureMessage {
-		t.Errorf("Test %s failed with error: \"%v\". error: %q expected", t.Name(), err, failureMessage)
-	}
-	a.parsed = false
-}
-
 func TestFlagAddArgumentFail(t *testing.T) {
 	type testCase struct {
 		testName, shortArg, longArg, failureMessage string
@@ -362,7 +350,8 @@ func TestFlagMultiShorthandWithParamFail1(t *testing.T) {
 		t.Errorf("Test %s failed with no error", t.Name())
 		return
 	}
-	errExpectation := "[-b|--bb] argument: The parameter must follow"
+	// The rest of the token is the value attached to the short name
+	errExpectation := "[-b|--bb] bad integer value [ab]"
 	if err.Error() != errExpectation {
 		t.Errorf("Test %s failed. error %q getted. %q expected", t.Name(), err.Error(), errExpectation)
 	}
@@ -2661,3 +2650,1841 @@ func TestCommandHelpSetSnameOnly(t *testing.T) {
 		t.Error("Help arugment names should have defaulted")
 	}
 }
+
+func TestUsageNilOptions(t *testing.T) {
+	expected := `usage: prog [-h|--help] [-f|--flag]
+
+            program description
+
+Arguments:
+
+  -h  --help  Print help information
+  -f  --flag 
+
+`
+	p := NewParser("prog", "program description")
+	p.Flag("f", "flag", nil)
+
+	if actual := p.Usage(nil); expected != actual {
+		t.Errorf("Expectations unmet. expected: %s, actual: %s", expected, actual)
+	}
+}
+
+func TestUsageEpilog(t *testing.T) {
+	expected := `usage: prog [-h|--help]
+
+            program description
+
+Arguments:
+
+  -h  --help  Print help information
+
+See https://example.com for details
+
+`
+	p := NewParser("prog", "program description")
+	p.SetEpilog("See https://example.com for details")
+
+	if p.GetEpilog() != "See https://example.com for details" {
+		t.Errorf("Unexpected epilog %q", p.GetEpilog())
+	}
+	if actual := p.Usage(nil); expected != actual {
+		t.Errorf("Expectations unmet. expected: %s, actual: %s", expected, actual)
+	}
+}
+
+func TestHelpModel(t *testing.T) {
+	p := NewParser("prog", "program description")
+	p.String("s", "string", &Options{Required: true, Help: "string help"})
+	p.Int("i", "int", &Options{Help: DisableDescription})
+	cmd := p.NewCommand("cmd", "cmd description")
+	p.NewCommand("hidden", DisableDescription)
+
+	model := p.GetHelpModel(errors.New("failure"))
+	if model.Message != "failure\n" {
+		t.Errorf("Unexpected message %q", model.Message)
+	}
+	if !reflect.DeepEqual(model.Chain, []string{"prog"}) {
+		t.Errorf("Unexpected chain %v", model.Chain)
+	}
+	if !reflect.DeepEqual(model.UsageItems, []string{"prog", "<Command>", "[-h|--help]", `-s|--string "<value>"`}) {
+		t.Errorf("Unexpected usage items %v", model.UsageItems)
+	}
+	if len(model.Commands) != 1 || model.Commands[0].Name != "cmd" || model.Commands[0].Description != "cmd description" {
+		t.Errorf("Unexpected commands %v", model.Commands)
+	}
+	if len(model.Arguments) != 2 {
+		t.Fatalf("Unexpected arguments %v", model.Arguments)
+	}
+	if a := model.Arguments[1]; a.Lname != "string" || !a.Required || a.Help != "string help" {
+		t.Errorf("Unexpected argument %v", a)
+	}
+
+	model = cmd.GetHelpModel(nil)
+	if model.Message != "" || !reflect.DeepEqual(model.Chain, []string{"prog", "cmd"}) {
+		t.Errorf("Unexpected command model %v", model)
+	}
+}
+
+func TestHelpTemplate(t *testing.T) {
+	p := NewParser("prog", "program description")
+	p.String("s", "string", &Options{Help: "string help"})
+	cmd := p.NewCommand("cmd", "cmd description")
+
+	err := p.SetHelpTemplate(`{{.Message}}{{range .Chain}}{{.}} {{end}}- {{.Description}}
+{{range .Arguments}}{{.Usage}}: {{.Help}}
+{{end}}`)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	expected := `prog - program description
+[-h|--help]: Print help information
+[-s|--string "<value>"]: string help
+`
+	if actual := p.Usage(nil); expected != actual {
+		t.Errorf("Expectations unmet. expected: %s, actual: %s", expected, actual)
+	}
+
+	// Sub-commands inherit template of their parent
+	expected = `error
+prog cmd - cmd description
+[-h|--help]: Print help information
+[-s|--string "<value>"]: string help
+`
+	if actual := cmd.Usage(errors.New("error")); expected != actual {
+		t.Errorf("Expectations unmet. expected: %s, actual: %s", expected, actual)
+	}
+
+	if err := p.SetHelpTemplate("{{.Unclosed"); err == nil {
+		t.Error("Setting malformed template should fail")
+	}
+}
+
+func TestDisplayWidth(t *testing.T) {
+	tt := []struct {
+		input string
+		width int
+	}{
+		{"", 0},
+		{"hello", 5},
+		{"héllo", 5},
+		{"héllo", 5},
+		{"你好", 4},
+		{"ｈｉ", 4},
+		{"🚀 go", 5},
+		{"\x1b[1;32mgreen\x1b[0m", 5},
+	}
+	for _, tc := range tt {
+		if w := displayWidth(tc.input); w != tc.width {
+			t.Errorf("displayWidth(%q) = %d, expected %d", tc.input, w, tc.width)
+		}
+	}
+
+	head, tail := splitAtWidth("你好世界", 5)
+	if head != "你好" || tail != "世界" {
+		t.Errorf("Unexpected split %q %q", head, tail)
+	}
+	head, tail = splitAtWidth("你好", 1)
+	if head != "你" || tail != "好" {
+		t.Errorf("Unexpected split %q %q", head, tail)
+	}
+}
+
+func TestUsageWideCharacters(t *testing.T) {
+	expected := `usage: prog [-h|--help] [-n|--名前 "<value>"]
+
+            程序描述
+
+Arguments:
+
+  -h  --help  Print help information
+  -n  --名前  这是一个很长的帮助信息 它包含许多中文字符
+              因此需要按照显示宽度 而不是字节数来换行
+
+`
+	p := NewParser("prog", "程序描述")
+	p.SetHelpWidth(60)
+	p.String("n", "名前", &Options{Help: "这是一个很长的帮助信息 它包含许多中文字符 因此需要按照显示宽度 而不是字节数来换行"})
+
+	if actual := p.Usage(nil); expected != actual {
+		t.Errorf("Expectations unmet. expected: %s, actual: %s", expected, actual)
+	}
+}
+
+func TestUsageLongWord(t *testing.T) {
+	expected := `usage: prog [-h|--help] [-u|--url "<value>"]
+
+            program description
+
+Arguments:
+
+  -h  --help  Print help information
+  -u  --url   Example:
+              https://example.com/a/very/long/path/that/does/no
+              t/fit/on/a/single/line
+
+`
+	p := NewParser("prog", "program description")
+	p.SetHelpWidth(64)
+	p.String("u", "url", &Options{Help: "Example: https://example.com/a/very/long/path/that/does/not/fit/on/a/single/line"})
+
+	if actual := p.Usage(nil); expected != actual {
+		t.Errorf("Expectations unmet. expected: %s, actual: %s", expected, actual)
+	}
+}
+
+func TestHelpWidthFromEnvironment(t *testing.T) {
+	defer func(f func(io.Writer) bool) { isTerminal = f }(isTerminal)
+	terminal := true
+	isTerminal = func(io.Writer) bool { return terminal }
+	t.Setenv("COLUMNS", "132")
+
+	p := NewParser("prog", "")
+	cmd := p.NewCommand("cmd", "")
+
+	if w := cmd.getHelpWidth(); w != 132 {
+		t.Errorf("Expected width from COLUMNS, got %d", w)
+	}
+	terminal = false
+	if w := cmd.getHelpWidth(); w != 80 {
+		t.Errorf("Expected COLUMNS to be ignored when output is not a terminal, got %d", w)
+	}
+	terminal = true
+	os.Setenv("COLUMNS", "invalid")
+	if w := cmd.getHelpWidth(); w != 80 {
+		t.Errorf("Expected default width, got %d", w)
+	}
+	p.SetHelpWidth(100)
+	if w := cmd.getHelpWidth(); w != 100 {
+		t.Errorf("Expected width inherited from parser, got %d", w)
+	}
+}
+
+func TestUsageColor(t *testing.T) {
+	expected := "\x1b[1;31mfailure\x1b[0m\n" +
+		"\x1b[1musage:\x1b[0m \x1b[1;36mprog\x1b[0m \x1b[33m<Command>\x1b[0m [\x1b[32m-h|--help\x1b[0m]\n" +
+		"            \x1b[1;32m-n|--num\x1b[0m \x1b[33m<integer>\x1b[0m\n" +
+		"\n" +
+		"            program description\n" +
+		"\n" +
+		"\x1b[1mCommands:\x1b[0m\n" +
+		"\n" +
+		"  \x1b[1;36mcmd\x1b[0m  cmd description\n" +
+		"\n" +
+		"\x1b[1mArguments:\x1b[0m\n" +
+		"\n" +
+		"  \x1b[32m-h\x1b[0m  \x1b[32m--help\x1b[0m  Print help information\n" +
+		"  \x1b[1;32m-n\x1b[0m  \x1b[1;32m--num\x1b[0m   number\n" +
+		"\n"
+
+	var buf strings.Builder
+	p := NewParser("prog", "program description")
+	p.Int("n", "num", &Options{Required: true, Help: "number"})
+	p.NewCommand("cmd", "cmd description")
+	p.SetHelpWidth(40)
+	p.SetOutput(&buf)
+	p.SetColor(&DefaultColorTheme)
+
+	// Buffer is not a terminal
+	if actual := p.Usage(nil); strings.Contains(actual, "\x1b[") {
+		t.Errorf("Colors should be disabled for non-terminal output: %q", actual)
+	}
+
+	p.ForceColor(true)
+	if actual := p.Usage(errors.New("failure")); expected != actual {
+		t.Errorf("Expectations unmet. expected: %q, actual: %q", expected, actual)
+	}
+
+	// Colors are not used in HelpModel
+	if model := p.GetHelpModel(nil); strings.Contains(model.Arguments[1].Heading, "\x1b[") {
+		t.Errorf("HelpModel should not be colored: %q", model.Arguments[1].Heading)
+	}
+}
+
+func TestUsageNoColor(t *testing.T) {
+	defer os.Setenv("NO_COLOR", os.Getenv("NO_COLOR"))
+	os.Setenv("NO_COLOR", "1")
+
+	p := NewParser("prog", "program description")
+	p.SetColor(&DefaultColorTheme)
+	p.SetOutput(os.Stdout)
+	if theme := p.getColorTheme(); theme != nil {
+		t.Errorf("Colors should be disabled with NO_COLOR")
+	}
+	p.ForceColor(true)
+	if theme := p.getColorTheme(); theme != &DefaultColorTheme {
+		t.Errorf("Colors should be enabled when forced")
+	}
+}
+
+func TestHelpOutput(t *testing.T) {
+	exit = func(n int) {}
+	var buf strings.Builder
+
+	p := NewParser("prog", "program description")
+	cmd := p.NewCommand("cmd", "cmd description")
+	p.SetOutput(&buf)
+
+	if err := p.Parse([]string{"prog", "cmd", "-h"}); err != nil {
+		t.Fatal(err)
+	}
+	if expected := cmd.Usage(nil) + "\n"; buf.String() != expected {
+		t.Errorf("Expectations unmet. expected: %s, actual: %s", expected, buf.String())
+	}
+}
+
+func TestUsageExamples(t *testing.T) {
+	expected := `usage: prog deploy [-e|--env "<value>"] [-h|--help]
+
+            deploy description
+
+Arguments:
+
+  -e  --env   target environment
+  -h  --help  Print help information
+
+Examples:
+
+  Deploy to staging
+    prog deploy --env staging
+    prog deploy -e production
+
+Environment:
+  PROG_TOKEN    access token,   not wrapped
+
+`
+	p := NewParser("prog", "program description")
+	cmd := p.NewCommand("deploy", "deploy description")
+	cmd.String("e", "env", &Options{Help: "target environment"})
+	cmd.AddExample("Deploy to staging", "prog deploy --env staging")
+	cmd.AddExample("", "prog deploy -e production")
+	cmd.SetEpilog("Environment:\n  PROG_TOKEN    access token,   not wrapped")
+
+	if len(cmd.GetExamples()) != 2 || cmd.GetExamples()[0].Command != "prog deploy --env staging" {
+		t.Errorf("Unexpected examples %v", cmd.GetExamples())
+	}
+	if actual := cmd.Usage(nil); expected != actual {
+		t.Errorf("Expectations unmet. expected: %s, actual: %s", expected, actual)
+	}
+	if len(p.GetHelpModel(nil).Examples) != 0 {
+		t.Errorf("Examples should not be inherited")
+	}
+}
+
+func TestSplitCommandLine(t *testing.T) {
+	tt := []struct {
+		line     string
+		expected []string
+	}{
+		{"", []string{}},
+		{"prog  -a   b", []string{"prog", "-a", "b"}},
+		{`prog --name "John Doe" 'single "quoted"'`, []string{"prog", "--name", "John Doe", `single "quoted"`}},
+		{`prog a\ b "c\"d" "e\f" ''`, []string{"prog", "a b", `c"d`, `e\f`, ""}},
+	}
+	for _, tc := range tt {
+		actual, err := splitCommandLine(tc.line)
+		if err != nil {
+			t.Errorf("%q: %s", tc.line, err)
+		} else if !reflect.DeepEqual(actual, tc.expected) {
+			t.Errorf("%q: got %q, expected %q", tc.line, actual, tc.expected)
+		}
+	}
+	if _, err := splitCommandLine(`prog "unterminated`); err == nil {
+		t.Error("Unterminated quote should fail")
+	}
+}
+
+func TestValidateExamples(t *testing.T) {
+	newParser := func() *Parser {
+		p := NewParser("prog", "program description")
+		p.Flag("v", "verbose", nil)
+		p.AddExample("Show help", "prog --help")
+		deploy := p.NewCommand("deploy", "deploy description")
+		deploy.String("e", "env", &Options{Required: true})
+		deploy.AddExample("Deploy to staging", "prog deploy --env 'staging eu' -v")
+		return p
+	}
+	if err := ValidateExamples(newParser); err != nil {
+		t.Error(err)
+	}
+
+	newBrokenParser := func() *Parser {
+		p := newParser()
+		p.AddExample("Renamed flag", "prog --verbosity")
+		p.commands[0].AddExample("Wrong command", "prog --verbose")
+		p.commands[0].AddExample("Missing required", "prog deploy")
+		return p
+	}
+	expected := `example "prog --verbosity": unknown arguments --verbosity
+example "prog --verbose": command deploy was not selected
+example "prog deploy": [-e|--env] is required`
+	if err := ValidateExamples(newBrokenParser); err == nil || err.Error() != expected {
+		t.Errorf("Expectations unmet. expected: %s, actual: %v", expected, err)
+	}
+}
+
+func TestValidateExamplesDoesNotOpenFiles(t *testing.T) {
+	dir := t.TempDir()
+	out := dir + "/out.txt"
+	def := dir + "/default.txt"
+	newParser := func() *Parser {
+		p := NewParser("prog", "program description")
+		p.File("o", "out", os.O_CREATE|os.O_WRONLY, 0600, nil)
+		p.FileList("i", "in", os.O_RDONLY, 0600, nil)
+		p.File("d", "default", os.O_CREATE|os.O_WRONLY, 0600, &Options{Default: def})
+		p.AddExample("Write output", "prog -o "+out+" -i "+dir+"/missing.txt")
+		return p
+	}
+	if err := ValidateExamples(newParser); err != nil {
+		t.Error(err)
+	}
+	for _, name := range []string{out, def} {
+		if _, err := os.Stat(name); !os.IsNotExist(err) {
+			t.Errorf("File %s should not be created by validation", name)
+		}
+	}
+}
+
+func TestUsageHidden2(t *testing.T) {
+	p := NewParser("verylongprogname", "prog description")
+
+	cmd1 := p.NewCommand("veryverylongcmd1", "cmd1 description")
+	_ = cmd1.Flag("f", "verylongflag1", &Options{Help: "flag1 description"})
+	_ = cmd1.Flag("a", "verylongflagA", &Options{Required: true, Help: "flag1 description"})
+	_ = p.String("s", "verylongstring-flag1", &Options{Help: "string1 description"})
+	_ = p.Int("i", "integer-flag1", &Options{Help: "integer1 description"})
+	_ = p.Int("I", "integer-flag2", &Options{Help: "integer2 description", Hidden: true})
+
+	_ = p.NewCommand("cmd2", "cmd2 description")
+
+	cmd3 := p.NewCommand("cmd3", "cmd3 description")
+	cmd3.Hidden = true
+
+	if pUsage != p.Usage(nil) {
+		t.Errorf("%s", p.Usage(nil))
+	}
+	if cmd1Usage != cmd1.Usage(nil) {
+		t.Errorf("%s", cmd1.Usage(nil))
+	}
+	if cmd3.GetDescription() != "cmd3 description" {
+		t.Errorf("Hidden command should keep its description")
+	}
+	if c := p.NewCommand("cmd4", DisableDescription); c.GetDescription() != "" {
+		t.Errorf("DisableDescription should not be exposed, got %q", c.GetDescription())
+	}
+}
+
+func TestHelpAll(t *testing.T) {
+	expected := `usage: prog <Command> [-h|--help] [--help-all] [-s|--secret "<value>"]
+
+            program description
+
+Commands:
+
+  cmd     cmd description
+  hidden  hidden description
+  legacy  
+
+Arguments:
+
+  -h  --help      Print help information
+      --help-all  Print help information including hidden commands and
+                  arguments
+  -s  --secret    secret description
+
+`
+	exit = func(n int) {}
+	var buf strings.Builder
+
+	p := NewParser("prog", "program description")
+	p.EnableHelpAll()
+	p.String("s", "secret", &Options{Help: "secret description", Hidden: true})
+	p.NewCommand("cmd", "cmd description")
+	p.NewCommand("hidden", "hidden description").Hidden = true
+	p.NewCommand("legacy", DisableDescription)
+	p.SetOutput(&buf)
+	p.SetHelpWidth(80)
+
+	if strings.Contains(p.Usage(nil), "help-all") || strings.Contains(p.Usage(nil), "hidden") {
+		t.Errorf("Hidden items should not be displayed:\n%s", p.Usage(nil))
+	}
+	if err := p.Parse([]string{"prog", "--help-all"}); err != nil {
+		t.Fatal(err)
+	}
+	if buf.String() != expected+"\n" {
+		t.Errorf("Expectations unmet. expected: %s, actual: %s", expected, buf.String())
+	}
+}
+
+func TestHelpCommand(t *testing.T) {
+	exited := false
+	exit = func(n int) {
+		exited = true
+	}
+	newParser := func(buf *strings.Builder) (*Parser, *Command) {
+		p := NewParser("prog", "program description")
+		deploy := p.NewCommand("deploy", "deploy description")
+		deploy.NewCommand("staging", "staging description")
+		p.NewCommand("destroy", "destroy description")
+		p.EnableHelpCommand()
+		p.SetOutput(buf)
+		return p, deploy
+	}
+
+	var buf strings.Builder
+	p, deploy := newParser(&buf)
+	if err := p.Parse([]string{"prog", "help", "deploy", "staging"}); err != nil {
+		t.Fatal(err)
+	}
+	if expected := deploy.commands[0].Usage(nil) + "\n"; buf.String() != expected || !exited {
+		t.Errorf("Expectations unmet. expected: %s, actual: %s", expected, buf.String())
+	}
+
+	buf.Reset()
+	p, _ = newParser(&buf)
+	p.ExitOnHelp(false)
+	if err := p.Parse([]string{"prog", "help"}); err != nil {
+		t.Fatal(err)
+	}
+	if !strings.Contains(buf.String(), "help     Print help information for a command") {
+		t.Errorf("Parser help should be printed, got: %s", buf.String())
+	}
+
+	p, _ = newParser(&buf)
+	err := p.Parse([]string{"prog", "help", "depoly"})
+	if expected := "unknown command depoly for prog. Did you mean deploy?"; err == nil || err.Error() != expected {
+		t.Errorf("Expectations unmet. expected: %s, actual: %v", expected, err)
+	}
+	p, _ = newParser(&buf)
+	err = p.Parse([]string{"prog", "help", "de"})
+	if expected := "unknown command de for prog. Did you mean deploy, destroy?"; err == nil || err.Error() != expected {
+		t.Errorf("Expectations unmet. expected: %s, actual: %v", expected, err)
+	}
+	p, _ = newParser(&buf)
+	err = p.Parse([]string{"prog", "help", "deploy", "production"})
+	if expected := "unknown command production for deploy"; err == nil || err.Error() != expected {
+		t.Errorf("Expectations unmet. expected: %s, actual: %v", expected, err)
+	}
+}
+
+func TestEditDistance(t *testing.T) {
+	tt := []struct {
+		a, b     string
+		distance int
+	}{
+		{"", "", 0},
+		{"deploy", "deploy", 0},
+		{"deploy", "depoly", 2},
+		{"deploy", "deploys", 1},
+		{"", "abc", 3},
+		{"日本", "日本語", 1},
+	}
+	for _, tc := range tt {
+		if d := editDistance(tc.a, tc.b); d != tc.distance {
+			t.Errorf("editDistance(%q, %q) = %d, expected %d", tc.a, tc.b, d, tc.distance)
+		}
+	}
+}
+
+func TestVersion(t *testing.T) {
+	exited := false
+	exit = func(n int) {
+		exited = true
+	}
+	var buf strings.Builder
+
+	p := NewParser("prog", "program description")
+	p.SetVersion("1.0.0")
+	p.SetVersion("1.2.3")
+	p.EnableVersionCommand()
+	p.SetOutput(&buf)
+
+	if len(p.args) != 2 || p.args[1].sname != "V" || p.args[1].lname != "version" {
+		t.Fatalf("Version argument should be added once")
+	}
+	if err := p.Parse([]string{"prog", "-V"}); err != nil {
+		t.Fatal(err)
+	}
+	if buf.String() != "prog 1.2.3\n" || !exited {
+		t.Errorf("Unexpected version output %q", buf.String())
+	}
+
+	buf.Reset()
+	p.ExitOnHelp(false)
+	exited = false
+	p.parsed = false
+	p.commands[0].parsed = false
+	if err := p.Parse([]string{"prog", "version"}); err != nil {
+		t.Fatal(err)
+	}
+	if buf.String() != "prog 1.2.3\n" || exited {
+		t.Errorf("Unexpected version output %q", buf.String())
+	}
+}
+
+func TestVersionFromBuildInfo(t *testing.T) {
+	defer func() {
+		readBuildInfo = debug.ReadBuildInfo
+	}()
+
+	tt := []struct {
+		info     *debug.BuildInfo
+		expected string
+	}{
+		{&debug.BuildInfo{}, "(devel)"},
+		{&debug.BuildInfo{Main: debug.Module{Version: "v1.2.3"}}, "v1.2.3"},
+		{&debug.BuildInfo{
+			Main: debug.Module{Version: "v1.2.3"},
+			Settings: []debug.BuildSetting{
+				{Key: "vcs.revision", Value: "0123456789abcdef0123"},
+				{Key: "vcs.modified", Value: "true"},
+			},
+		}, "v1.2.3 (rev 0123456789ab, modified)"},
+		{&debug.BuildInfo{
+			Main:     debug.Module{Version: "(devel)"},
+			Settings: []debug.BuildSetting{{Key: "vcs.revision", Value: "abc"}},
+		}, "(devel) (rev abc)"},
+	}
+	for _, tc := range tt {
+		readBuildInfo = func() (*debug.BuildInfo, bool) {
+			return tc.info, true
+		}
+		p := NewParser("prog", "")
+		if !p.SetVersionFromBuildInfo() || p.version != tc.expected {
+			t.Errorf("Expected version %q, got %q", tc.expected, p.version)
+		}
+	}
+
+	readBuildInfo = func() (*debug.BuildInfo, bool) {
+		return nil, false
+	}
+	if p := NewParser("prog", ""); p.SetVersionFromBuildInfo() || len(p.args) != 1 {
+		t.Errorf("Version should not be set without build information")
+	}
+}
+
+func TestFlagNegatable(t *testing.T) {
+	tt := []struct {
+		args     []string
+		expected bool
+		err      string
+	}{
+		{[]string{"prog"}, true, ""},
+		{[]string{"prog", "--cache"}, true, ""},
+		{[]string{"prog", "--no-cache"}, false, ""},
+		{[]string{"prog", "-c"}, true, ""},
+		{[]string{"prog", "--cache=false"}, false, ""},
+		{[]string{"prog", "--cache=No"}, false, ""},
+		{[]string{"prog", "--cache=0"}, false, ""},
+		{[]string{"prog", "--cache=yes"}, true, ""},
+		{[]string{"prog", "-c=false"}, false, ""},
+		{[]string{"prog", "--cache=maybe"}, true, "[-c|--cache] bad boolean value [maybe]"},
+		{[]string{"prog", "--no-cache=true"}, true, "[-c|--cache] negation does not take a value"},
+		{[]string{"prog", "--cache", "--no-cache"}, true, "[-c|--cache] can only be present once"},
+	}
+	for _, tc := range tt {
+		p := NewParser("prog", "")
+		cache := p.Flag("c", "cache", &Options{Default: true, Negatable: true})
+		err := p.Parse(tc.args)
+		if tc.err != "" {
+			if err == nil || err.Error() != tc.err {
+				t.Errorf("%v: expected error %q, got %v", tc.args, tc.err, err)
+			}
+			continue
+		}
+		if err != nil {
+			t.Errorf("%v: %s", tc.args, err)
+		} else if *cache != tc.expected {
+			t.Errorf("%v: expected %t, got %t", tc.args, tc.expected, *cache)
+		}
+	}
+
+	p := NewParser("prog", "")
+	p.Flag("v", "verbose", nil)
+	if err := p.Parse([]string{"prog", "--no-verbose"}); err == nil || err.Error() != "unknown arguments --no-verbose" {
+		t.Errorf("Flag should not be negatable without option, got %v", err)
+	}
+	p = NewParser("prog", "")
+	verbose := p.Flag("v", "verbose", nil)
+	if err := p.Parse([]string{"prog", "--verbose=false"}); err != nil || *verbose {
+		t.Errorf("Flag should accept explicit value, got %t, %v", *verbose, err)
+	}
+}
+
+func TestUsageNegatable(t *testing.T) {
+	expected := `usage: prog [-h|--help] [-c|--[no-]cache] [--[no-]color]
+
+            program description
+
+Arguments:
+
+  -h  --help        Print help information
+  -c  --[no-]cache  Use cache. Default: true
+      --[no-]color  Use colors
+
+`
+	p := NewParser("prog", "program description")
+	p.Flag("c", "cache", &Options{Default: true, Negatable: true, Help: "Use cache"})
+	p.Flag("", "color", &Options{Negatable: true, Help: "Use colors"})
+
+	if actual := p.Usage(nil); expected != actual {
+		t.Errorf("Expectations unmet. expected: %s, actual: %s", expected, actual)
+	}
+}
+
+func TestNullable(t *testing.T) {
+	p := NewParser("prog", "")
+	f := p.NullableFlag("f", "flag", nil)
+	s := p.NullableString("s", "string", nil)
+	i := p.NullableInt("i", "int", &Options{Default: 5})
+	fl := p.NullableFloat("", "float", nil)
+
+	if err := p.Parse([]string{"prog", "--flag=false", "-i", "0"}); err != nil {
+		t.Fatal(err)
+	}
+	if *f == nil || **f != false {
+		t.Errorf("Flag should be set to false")
+	}
+	if *i == nil || **i != 0 {
+		t.Errorf("Int should be set to 0")
+	}
+	if *s != nil || *fl != nil {
+		t.Errorf("Arguments that were not provided should stay nil")
+	}
+
+	if !p.GetArg("int").GetParsed() || p.GetArg("string").GetParsed() {
+		t.Errorf("GetParsed should tell whether argument was provided")
+	}
+	if p.GetArg("unknown") != nil {
+		t.Errorf("GetArg should return nil for unknown argument")
+	}
+}
+
+func TestNullableIgnoresDefault(t *testing.T) {
+	p := NewParser("prog", "")
+	i := p.NullableInt("i", "int", &Options{Default: 5})
+
+	if err := p.Parse([]string{"prog"}); err != nil {
+		t.Fatal(err)
+	}
+	if *i != nil {
+		t.Errorf("Nullable argument that was not provided should stay nil, got %d", **i)
+	}
+	arg := p.GetArg("int")
+	if v := arg.GetValue(); v != 0 {
+		t.Errorf("Default value should not be applied to nullable argument, got %v", v)
+	}
+	if source := arg.GetSource(); source.Kind != SourceNone {
+		t.Errorf("Expected no source of nullable argument, got %#v", source)
+	}
+}
+
+func TestGetParsed(t *testing.T) {
+	p := NewParser("prog", "")
+	cmd := p.NewCommand("cmd", "")
+	p.Int("c", "count", &Options{Default: 3})
+	cmd.Flag("v", "verbose", nil)
+	cmd.Flag("q", "quiet", &Options{Default: true})
+
+	if err := p.Parse([]string{"prog", "cmd", "-v"}); err != nil {
+		t.Fatal(err)
+	}
+	if cmd.GetArg("count").GetParsed() || cmd.GetArg("quiet").GetParsed() {
+		t.Errorf("Arguments set to default value should not be parsed")
+	}
+	if !cmd.GetArg("verbose").GetParsed() {
+		t.Errorf("Provided argument should be parsed")
+	}
+	if p.GetArg("verbose") != nil {
+		t.Errorf("Parser should not see arguments of its commands")
+	}
+}
+
+func TestSource(t *testing.T) {
+	p := NewParser("prog", "")
+	cmd := p.NewCommand("cmd", "")
+	p.String("n", "name", &Options{Default: "anonymous"})
+	p.Int("c", "count", nil)
+	cmd.Flag("v", "verbose", nil)
+	cmd.FlagCounter("d", "debug", nil)
+	cmd.StringList("t", "tag", nil)
+	cmd.Float("r", "ratio", nil)
+
+	if err := p.Parse([]string{"prog", "cmd", "-t", "a", "-v", "--ratio=0.5", "-dd", "--tag", "b"}); err != nil {
+		t.Fatal(err)
+	}
+
+	tt := []struct {
+		name     string
+		expected Source
+		str      string
+	}{
+		{"name", Source{Kind: SourceDefault, Raw: "anonymous"}, "default"},
+		{"count", Source{}, "none"},
+		{"verbose", Source{Kind: SourceCommandLine, Index: 4, Raw: "-v"}, `command line (argument 4: "-v")`},
+		{"debug", Source{Kind: SourceCommandLine, Index: 6, Raw: "-dd"}, `command line (argument 6: "-dd")`},
+		{"tag", Source{Kind: SourceCommandLine, Index: 7, Raw: "b"}, `command line (argument 7: "b")`},
+		{"ratio", Source{Kind: SourceCommandLine, Index: 5, Raw: "0.5"}, `command line (argument 5: "0.5")`},
+	}
+	for _, tc := range tt {
+		source := cmd.GetArg(tc.name).GetSource()
+		if source != tc.expected {
+			t.Errorf("%s: expected source %#v, got %#v", tc.name, tc.expected, source)
+		}
+		if source.String() != tc.str {
+			t.Errorf("%s: expected %q, got %q", tc.name, tc.str, source.String())
+		}
+	}
+}
+
+func TestSetValue(t *testing.T) {
+	p := NewParser("prog", "")
+	cmd := p.NewCommand("cmd", "")
+	c := p.Int("c", "count", nil)
+	v := cmd.Flag("v", "verbose", nil)
+	tags := cmd.StringList("t", "tag", &Options{Default: []string{"default"}})
+	name := cmd.String("n", "name", nil)
+
+	if err := p.Parse([]string{"prog", "cmd", "-n", "cli"}); err != nil {
+		t.Fatal(err)
+	}
+	env := Source{Kind: SourceEnvironment, Name: "COUNT", Raw: "5"}
+	if err := cmd.SetValue("count", []string{"5"}, env); err != nil {
+		t.Fatal(err)
+	}
+	config := Source{Kind: SourceConfig, Name: "tags", Path: "app.conf", Line: 3, Raw: "a,b"}
+	if err := cmd.SetValue("tag", []string{"a", "b"}, config); err != nil {
+		t.Fatal(err)
+	}
+	if err := cmd.SetValue("verbose", nil, Source{Kind: SourceConfig, Name: "verbose", Path: "app.conf"}); err != nil {
+		t.Fatal(err)
+	}
+	if *c != 5 || !*v || !reflect.DeepEqual(*tags, []string{"a", "b"}) {
+		t.Errorf("Unexpected values %d, %t, %v", *c, *v, *tags)
+	}
+
+	tt := []struct {
+		name string
+		str  string
+	}{
+		{"count", `environment ($COUNT: "5")`},
+		{"tag", `config (app.conf:3 tags: "a,b")`},
+		{"verbose", `config (app.conf verbose: "")`},
+	}
+	for _, tc := range tt {
+		arg := cmd.GetArg(tc.name)
+		if !arg.GetParsed() {
+			t.Errorf("%s: expected argument set with SetValue to be parsed", tc.name)
+		}
+		if s := arg.GetSource().String(); s != tc.str {
+			t.Errorf("%s: expected %q, got %q", tc.name, tc.str, s)
+		}
+	}
+
+	if err := cmd.SetValue("name", []string{"env"}, env); err == nil ||
+		err.Error() != "[-n|--name] was provided on command line" || *name != "cli" {
+		t.Errorf("Expected error setting argument provided on command line, got %v and %q", err, *name)
+	}
+	if err := cmd.SetValue("count", []string{"x"}, env); err == nil || err.Error() != "[-c|--count] bad integer value [x]" {
+		t.Errorf("Unexpected error %v", err)
+	}
+	if err := cmd.SetValue("unknown", nil, env); err == nil || err.Error() != "unknown argument [unknown]" {
+		t.Errorf("Unexpected error %v", err)
+	}
+}
+
+func TestSetValueLayers(t *testing.T) {
+	p := NewParser("prog", "")
+	user := p.String("u", "user", &Options{Default: "nobody"})
+	tags := p.StringList("t", "tag", &Options{Default: []string{"default"}})
+	limits := p.StringMap("l", "limit", nil)
+	verbose := p.Flag("v", "verbose", nil)
+
+	if err := p.Parse([]string{"prog"}); err != nil {
+		t.Fatal(err)
+	}
+	config := Source{Kind: SourceConfig, Path: "app.conf"}
+	env := Source{Kind: SourceEnvironment}
+	layers := []struct {
+		source Source
+		values map[string][]string
+	}{
+		{config, map[string][]string{"user": {"admin"}, "tag": {"a", "b"}, "limit": {"cpu=1", "mem=2"}, "verbose": nil}},
+		{env, map[string][]string{"user": {"root"}, "tag": {"c"}, "limit": {"cpu=4"}, "verbose": {"false"}}},
+	}
+	for _, layer := range layers {
+		for name, values := range layer.values {
+			source := layer.source
+			source.Name = name
+			if err := p.SetValue(name, values, source); err != nil {
+				t.Fatal(err)
+			}
+		}
+	}
+	if *user != "root" || !reflect.DeepEqual(*tags, []string{"c"}) ||
+		!reflect.DeepEqual(*limits, map[string]string{"cpu": "4"}) || *verbose {
+		t.Errorf("Expected values of the last source, got %q, %v, %v, %t", *user, *tags, *limits, *verbose)
+	}
+	for _, name := range []string{"user", "tag", "limit", "verbose"} {
+		if source := p.GetArg(name).GetSource(); source.Kind != SourceEnvironment || source.Name != name {
+			t.Errorf("%s: expected source of the last layer, got %#v", name, source)
+		}
+	}
+}
+
+func TestArgIntrospection(t *testing.T) {
+	p := NewParser("prog", "")
+	cmd := p.NewCommand("cmd", "")
+	p.Flag("f", "flag", nil)
+	p.FlagCounter("v", "verbose", nil)
+	cmd.Int("i", "int", &Options{Default: 7})
+	cmd.Selector("s", "selector", []string{"a", "b"}, nil)
+	cmd.StringList("l", "list", nil)
+	p.SetVersion("1.0")
+
+	if err := p.Parse([]string{"prog", "cmd", "-vv", "--list=x", "--list", "y", "-s", "b"}); err != nil {
+		t.Fatal(err)
+	}
+
+	tt := []struct {
+		name     string
+		typ      string
+		arity    int
+		selector []string
+		value    interface{}
+		parent   *Command
+	}{
+		{"help", "help", 0, nil, nil, &p.Command},
+		{"flag", "flag", 0, nil, false, &p.Command},
+		{"verbose", "counter", 0, nil, 2, &p.Command},
+		{"int", "int", 1, nil, 7, cmd},
+		{"selector", "selector", 1, []string{"a", "b"}, "b", cmd},
+		{"list", "stringList", 1, nil, []string{"x", "y"}, cmd},
+		{"version", "version", 0, nil, nil, &p.Command},
+	}
+	for _, tc := range tt {
+		a := cmd.GetArg(tc.name)
+		if a.GetType() != tc.typ {
+			t.Errorf("%s: expected type %s, got %s", tc.name, tc.typ, a.GetType())
+		}
+		if a.GetArity() != tc.arity {
+			t.Errorf("%s: expected arity %d, got %d", tc.name, tc.arity, a.GetArity())
+		}
+		if !reflect.DeepEqual(a.GetSelector(), tc.selector) {
+			t.Errorf("%s: expected selector %v, got %v", tc.name, tc.selector, a.GetSelector())
+		}
+		if !reflect.DeepEqual(a.GetValue(), tc.value) {
+			t.Errorf("%s: expected value %v, got %v", tc.name, tc.value, a.GetValue())
+		}
+		if a.GetParent() != tc.parent {
+			t.Errorf("%s: unexpected parent %s", tc.name, a.GetParent().GetName())
+		}
+	}
+
+	if !reflect.DeepEqual(cmd.GetPath(), []string{"prog", "cmd"}) {
+		t.Errorf("Unexpected path %v", cmd.GetPath())
+	}
+	if !cmd.GetExitOnHelp() {
+		t.Errorf("Command should exit on help by default")
+	}
+}
+
+var schemaJSON = `{
+  "version": 1,
+  "parser": {
+    "name": "prog",
+    "description": "program description",
+    "version": "1.0",
+    "arguments": [
+      {
+        "short": "h",
+        "long": "help",
+        "type": "help",
+        "arity": 0,
+        "help": "Print help information"
+      },
+      {
+        "short": "V",
+        "long": "version",
+        "type": "version",
+        "arity": 0,
+        "help": "Print version information"
+      },
+      {
+        "short": "l",
+        "long": "level",
+        "type": "selector",
+        "arity": 1,
+        "required": true,
+        "default": "info",
+        "choices": [
+          "debug",
+          "info"
+        ],
+        "help": "log level"
+      }
+    ],
+    "commands": [
+      {
+        "name": "run",
+        "description": "run description",
+        "hidden": true,
+        "examples": [
+          {
+            "command": "prog run -c"
+          }
+        ],
+        "arguments": [
+          {
+            "short": "c",
+            "long": "cache",
+            "type": "flag",
+            "arity": 0,
+            "negatable": true
+          }
+        ]
+      }
+    ]
+  }
+}`
+
+func TestExportSchema(t *testing.T) {
+	p := NewParser("prog", "program description")
+	p.SetVersion("1.0")
+	p.Selector("l", "level", []string{"debug", "info"}, &Options{Required: true, Default: "info", Help: "log level"})
+	run := p.NewCommand("run", "run description")
+	run.Hidden = true
+	run.Flag("c", "cache", &Options{Negatable: true})
+	run.AddExample("", "prog run -c")
+
+	data, err := p.ExportSchema()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(data) != schemaJSON {
+		t.Errorf("Expectations unmet. expected: %s, actual: %s", schemaJSON, data)
+	}
+}
+
+// compareSchema - checks that schema describes command
+func compareSchema(t *testing.T, s CommandSchema, c *Command) {
+	if s.Name != c.GetName() || s.Description != c.GetDescription() || s.Hidden != c.isHidden() ||
+		s.Epilog != c.GetEpilog() || !reflect.DeepEqual(s.Examples, c.GetExamples()) {
+		t.Errorf("Schema of command %s does not match: %#v", c.GetName(), s)
+	}
+	if len(s.Arguments) != len(c.GetArgs()) {
+		t.Fatalf("Schema of command %s has %d arguments, expected %d", c.GetName(), len(s.Arguments), len(c.GetArgs()))
+	}
+	for i, a := range c.GetArgs() {
+		as := s.Arguments[i]
+		if as.Short != a.GetSname() || as.Long != a.GetLname() || as.Type != a.GetType() || as.Arity != a.GetArity() ||
+			!reflect.DeepEqual(as.Choices, a.GetSelector()) {
+			t.Errorf("Schema of argument %s does not match: %#v", a.GetLname(), as)
+		}
+		if opts := a.GetOpts(); opts != nil {
+			if as.Required != opts.Required || as.Help != opts.Help || as.Negatable != opts.Negatable ||
+				fmt.Sprint(as.Default) != fmt.Sprint(opts.Default) {
+				t.Errorf("Schema of argument %s options does not match: %#v", a.GetLname(), as)
+			}
+		}
+	}
+	if len(s.Commands) != len(c.GetCommands()) {
+		t.Fatalf("Schema of command %s has %d commands, expected %d", c.GetName(), len(s.Commands), len(c.GetCommands()))
+	}
+	for i, sub := range c.GetCommands() {
+		compareSchema(t, s.Commands[i], sub)
+	}
+}
+
+func TestExportSchemaRoundTrip(t *testing.T) {
+	p := NewParser("prog", "program description")
+	p.EnableHelpAll()
+	p.EnableHelpCommand()
+	p.SetEpilog("epilog")
+	p.String("s", "string", &Options{Default: "value", Help: "string help"})
+	p.IntList("i", "ints", &Options{Default: []int{1, 2}})
+	p.NullableFloat("", "ratio", &Options{Required: true})
+	p.FileList("f", "file", os.O_RDONLY, 0600, nil)
+	cmd := p.NewCommand("cmd", "cmd description")
+	cmd.FlagCounter("v", "verbose", nil)
+	cmd.AddExample("Verbose", "prog cmd -vv")
+	sub := cmd.NewCommand("sub", "sub description")
+	sub.StringList("t", "tag", &Options{Hidden: true})
+
+	data, err := p.ExportSchema()
+	if err != nil {
+		t.Fatal(err)
+	}
+	var schema Schema
+	if err := json.Unmarshal(data, &schema); err != nil {
+		t.Fatal(err)
+	}
+	if schema.Version != SchemaVersion {
+		t.Errorf("Unexpected schema version %d", schema.Version)
+	}
+	compareSchema(t, schema.Parser, &p.Command)
+	if schema.Parser.Commands[0].Builtin != "help" || schema.Parser.Arguments[5].FilePerm != 0600 ||
+		!schema.Parser.Arguments[4].Nullable {
+		t.Errorf("Unexpected schema %s", data)
+	}
+}
+
+func TestParserFromSpecRoundTrip(t *testing.T) {
+	p := NewParser("prog", "program description")
+	p.SetHelp("?", "usage")
+	p.SetVersion("2.0")
+	p.EnableHelpAll()
+	p.EnableHelpCommand()
+	p.EnableVersionCommand()
+	p.SetEpilog("epilog")
+	p.Flag("c", "cache", &Options{Default: true, Negatable: true})
+	p.Selector("l", "level", []string{"debug", "info"}, &Options{Default: "info"})
+	p.IntList("i", "ints", &Options{Default: []int{1, 2}})
+	p.FloatList("", "floats", &Options{Default: []float64{1.5}})
+	p.NullableInt("n", "num", &Options{Required: true, Help: "number"})
+	p.FileList("f", "file", os.O_RDONLY, 0600, &Options{Default: []string{}})
+	cmd := p.NewCommand("cmd", "cmd description")
+	cmd.Hidden = true
+	cmd.FlagCounter("v", "verbose", &Options{Default: 2})
+	cmd.Float("r", "ratio", &Options{Default: 0.5})
+	cmd.AddExample("Verbose", "prog cmd -vv")
+	sub := cmd.NewCommand("sub", "sub description")
+	sub.StringList("t", "tag", &Options{Hidden: true, Default: []string{"a"}})
+	sub.File("o", "output", os.O_WRONLY|os.O_CREATE, 0644, nil)
+	sub.IntMap("", "limit", &Options{DuplicateKeys: DuplicateKeyKeepFirst})
+	sub.SetOrdering(StopAtNonOption)
+
+	expected, err := p.ExportSchema()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !strings.Contains(string(expected), `"duplicateKeys": "keepFirst"`) {
+		t.Errorf("Expected duplicate key policy to be exported by name: %s", expected)
+	}
+	if !strings.Contains(string(expected), `"ordering": "stopAtNonOption"`) {
+		t.Errorf("Expected ordering to be exported by name: %s", expected)
+	}
+	built, err := NewParserFromSpec(expected, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	actual, err := built.ExportSchema()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(expected) != string(actual) {
+		t.Errorf("Expectations unmet. expected: %s, actual: %s", expected, actual)
+	}
+	if p.Usage(nil) != built.Usage(nil) {
+		t.Errorf("Usage of built parser differs:\n%s", built.Usage(nil))
+	}
+}
+
+func TestParserFromSpecHandlers(t *testing.T) {
+	spec := `{
+  "version": 1,
+  "parser": {
+    "name": "prog",
+    "arguments": [
+      {"short": "h", "long": "help", "type": "help"},
+      {"short": "v", "long": "verbose", "type": "flag"}
+    ],
+    "commands": [
+      {
+        "name": "deploy",
+        "arguments": [{"short": "e", "long": "env", "type": "string", "required": true}],
+        "commands": [{"name": "now"}]
+      },
+      {"name": "status"}
+    ]
+  }
+}`
+	var calls []string
+	handler := func(name string) func(c *Command) error {
+		return func(c *Command) error {
+			calls = append(calls, name+":"+strings.Join(c.GetPath(), " "))
+			if name == "deploy" {
+				calls = append(calls, c.GetArg("env").GetValue().(string))
+			}
+			return nil
+		}
+	}
+	handlers := map[string]func(c *Command) error{
+		"":       handler("root"),
+		"deploy": handler("deploy"),
+	}
+
+	p, err := NewParserFromSpec([]byte(spec), handlers)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := p.Run([]string{"prog", "deploy", "now", "-e", "prod"}); err != nil {
+		t.Fatal(err)
+	}
+	p, _ = NewParserFromSpec([]byte(spec), handlers)
+	if err := p.Run([]string{"prog", "status", "-v"}); err != nil {
+		t.Fatal(err)
+	}
+	expected := []string{"deploy:prog deploy now", "prod", "root:prog status"}
+	if !reflect.DeepEqual(calls, expected) {
+		t.Errorf("Expected calls %v, got %v", expected, calls)
+	}
+
+	if _, err := NewParserFromSpec([]byte(spec), map[string]func(c *Command) error{"deploy later": nil}); err == nil ||
+		err.Error() != `handler path "deploy later" does not match any command` {
+		t.Errorf("Unexpected error %v", err)
+	}
+}
+
+func TestParserFromSpecFail(t *testing.T) {
+	tt := []struct {
+		spec, err string
+	}{
+		{`{"version": 2, "parser": {"name": "prog"}}`, "unsupported schema version 2"},
+		{`{"version": 1, "parser": {"name": "prog", "arguments": [{"long": "x", "type": "unknown"}]}}`,
+			"command prog: argument x: unknown type unknown"},
+		{`{"version": 1, "parser": {"name": "prog", "arguments": [{"long": "x", "type": "int", "default": 1.5}]}}`,
+			"command prog: argument x: default value 1.5 cannot be used for type int"},
+		{`{"version": 1, "parser": {"name": "prog", "arguments": [{"short": "xy", "long": "x", "type": "flag"}]}}`,
+			"command prog: argument x: unable to add Flag: short name must not exceed 1 character"},
+		{`{"version": 1, "parser": {"name": "prog", "commands": [{"name": "c", "arguments": [{"long": "v", "type": "version"}]}]}}`,
+			"command c: argument v: only parser can have version argument"},
+		{`{"version": 1, "parser": {"name": "prog", "arguments": [{"long": "x", "type": "intMap", "duplicateKeys": "first"}]}}`,
+			`unknown duplicate key policy "first"`},
+		{`{"version": 1, "parser": {"name": "prog", "ordering": "posix"}}`, `unknown ordering "posix"`},
+	}
+	for _, tc := range tt {
+		if _, err := NewParserFromSpec([]byte(tc.spec), nil); err == nil || err.Error() != tc.err {
+			t.Errorf("Expected error %q, got %v", tc.err, err)
+		}
+	}
+}
+
+func TestDeferErrors(t *testing.T) {
+	p := NewParser("prog", "")
+	p.DeferErrors(true)
+	p.Flag("f", "flag", nil)
+	cmd := p.NewCommand("cmd", "")
+	cmd.String("f", "string", nil)
+	cmd.Int("", "", nil)
+	cmd.IntList("ab", "list", nil)
+	p.SetVersion("1.0")
+	p.SetVersion("1.1")
+	p.Selector("x", "flag", []string{"a"}, nil)
+	p.Int("i", "int", nil)
+
+	expected := `unable to add String: short name f occurs more than once
+unable to add Int: long name should be provided
+unable to add IntList: short name must not exceed 1 character
+unable to add Selector: long name flag occurs more than once`
+	if err := p.Validate(); err == nil || err.Error() != expected {
+		t.Errorf("Expectations unmet. expected: %s, actual: %v", expected, err)
+	}
+	if err := p.Parse([]string{"prog", "-i", "1"}); err == nil || err.Error() != expected {
+		t.Errorf("Parse should fail with definition errors, got %v", err)
+	}
+	var defErrors DefinitionErrors
+	if err := p.Validate(); !errors.As(err, &defErrors) || len(defErrors) != 4 ||
+		defErrors[1].Error() != "unable to add Int: long name should be provided" {
+		t.Errorf("Expected 4 DefinitionErrors, got %#v", err)
+	}
+	if len(cmd.args) != 0 || len(p.args) != 4 {
+		t.Errorf("Arguments that failed to be added should be ignored")
+	}
+
+	p = NewParser("prog", "")
+	p.DeferErrors(true)
+	p.Flag("f", "flag", nil)
+	if err := p.Validate(); err != nil {
+		t.Errorf("Validate should not fail without errors, got %v", err)
+	}
+}
+
+func TestDeferErrorsDisabled(t *testing.T) {
+	defer func() {
+		if r := recover(); r == nil || fmt.Sprint(r) != "unable to add Flag: long name flag occurs more than once" {
+			t.Errorf("Adding duplicate argument should panic, got %v", r)
+		}
+	}()
+	p := NewParser("prog", "")
+	p.Flag("f", "flag", nil)
+	p.Flag("g", "flag", nil)
+}
+
+func TestLint(t *testing.T) {
+	p := NewParser("prog", "program description")
+	cmd := p.NewCommand("cmd", "")
+	cmd.Flag("v", "verbose", nil)
+	cmd.String("o", "output", nil)
+	sub := cmd.NewCommand("sub", "sub description")
+	sub.Selector("l", "level", []string{"debug", "info"}, &Options{Default: "warn"})
+	sub.Selector("m", "mode", []string{}, nil)
+	sub.Int("n", "num", &Options{Default: "5", Required: true})
+	sub.NullableInt("", "port", &Options{Default: 80})
+	sub.String("s", "string", &Options{Negatable: true})
+	sub.File("f", "file", os.O_RDONLY, 0600, &Options{Default: 1})
+	sub.FileList("", "files", os.O_RDONLY, 0600, &Options{Default: []string{"a"}})
+	p.Flag("V", "verbose", nil)
+	p.String("o", "out", nil)
+	p.EnableHelpCommand()
+
+	expected := []string{
+		"command prog cmd: no description",
+		"command prog cmd: argument [-v|--verbose] shadows long name of argument [-V|--verbose] of command prog",
+		"command prog cmd: argument [-o|--output] shadows short name of argument [-o|--out] of command prog",
+		"command prog cmd sub: argument [-l|--level] default value warn is not among allowed values [debug info]",
+		"command prog cmd sub: argument [-m|--mode] has no allowed values",
+		"command prog cmd sub: argument [-n|--num] cannot use default type [string] as value of pointer with type [*int]",
+		"command prog cmd sub: argument [-n|--num] is required and has default value, which is never used",
+		"command prog cmd sub: argument [--port] is nullable and its default value is ignored",
+		"command prog cmd sub: argument [-s|--string] is not a flag and cannot be negatable",
+		"command prog cmd sub: argument [-f|--file] cannot use default type [int] as value of pointer with type [*string]",
+	}
+	problems := p.Lint()
+	actual := make([]string, 0, len(problems))
+	for _, problem := range problems {
+		actual = append(actual, problem.Error())
+	}
+	if !reflect.DeepEqual(expected, actual) {
+		t.Errorf("Expectations unmet. expected:\n%s\nactual:\n%s", strings.Join(expected, "\n"), strings.Join(actual, "\n"))
+	}
+
+	p = NewParser("prog", "program description")
+	p.NewCommand("cmd", "cmd description").Selector("l", "level", []string{"debug"}, &Options{Default: "debug"})
+	if problems := p.Lint(); len(problems) != 0 {
+		t.Errorf("Unexpected problems %v", problems)
+	}
+}
+
+func TestCollectErrors(t *testing.T) {
+	p := NewParser("prog", "description")
+	p.CollectErrors(true)
+	p.String("a", "aaa", &Options{Required: true})
+	cmd := p.NewCommand("cmd", "cmd description")
+	cmd.Int("n", "num", nil)
+	cmd.String("b", "bbb", &Options{Required: true})
+	cmd.Selector("l", "level", []string{"debug", "info"}, nil)
+
+	err := p.Parse([]string{"prog", "cmd", "-n", "x", "--level=warn", "--bogus", "value"})
+	if err == nil {
+		t.Fatal("Parsing should fail")
+	}
+	expected := `6 errors occurred:
+  * [-n|--num] bad integer value [x]
+  * bad value for [-l|--level]. Allowed values are [debug info]
+  * [-b|--bbb] is required
+  * [-a|--aaa] is required
+  * unknown argument --bogus
+  * unknown argument value`
+	if err.Error() != expected {
+		t.Errorf("Expected error:\n%s\nactual:\n%s", expected, err.Error())
+	}
+
+	var parseErrors ParseErrors
+	if !errors.As(err, &parseErrors) || len(parseErrors) != 6 {
+		t.Fatalf("Expected 6 ParseErrors, got %#v", err)
+	}
+	var invalid InvalidArgumentError
+	if !errors.As(err, &invalid) || invalid.Arg.GetLname() != "num" {
+		t.Errorf("Expected InvalidArgumentError of num, got %#v", invalid)
+	}
+	var missing MissingArgumentError
+	if !errors.As(err, &missing) || missing.Arg.GetLname() != "bbb" {
+		t.Errorf("Expected MissingArgumentError of bbb, got %#v", missing)
+	}
+	var unknown UnknownArgumentError
+	if !errors.As(err, &unknown) || unknown.Token != "--bogus" {
+		t.Errorf("Expected UnknownArgumentError of --bogus, got %#v", unknown)
+	}
+
+	usage := p.Usage(err)
+	if !strings.HasPrefix(usage, "6 errors occurred:\n  * [-n|--num] bad integer value [x]\n") ||
+		!strings.Contains(usage, "usage: prog cmd") {
+		t.Errorf("Unexpected usage:\n%s", usage)
+	}
+
+	p = NewParser("prog", "description")
+	p.CollectErrors(true)
+	p.NewCommand("cmd", "cmd description")
+	err = p.Parse([]string{"prog"})
+	if err == nil || err.Error() != "1 error occurred:\n  * [sub]Command required" {
+		t.Errorf("Unexpected error %v", err)
+	}
+
+	p = NewParser("prog", "description")
+	p.CollectErrors(true)
+	p.String("a", "aaa", nil)
+	if err := p.Parse([]string{"prog", "-a", "value"}); err != nil {
+		t.Errorf("Unexpected error %v", err)
+	}
+}
+
+func TestCollectErrorsInvalidNotMissing(t *testing.T) {
+	p := NewParser("prog", "description")
+	p.CollectErrors(true)
+	n := p.Int("n", "num", &Options{Required: true})
+	m := p.Int("m", "max", &Options{Default: 5})
+
+	err := p.Parse([]string{"prog", "-n", "abc", "-m", "xyz"})
+	expected := `2 errors occurred:
+  * [-n|--num] bad integer value [abc]
+  * [-m|--max] bad integer value [xyz]`
+	if err == nil || err.Error() != expected {
+		t.Errorf("Expected error:\n%s\nactual:\n%v", expected, err)
+	}
+	if *n != 0 || *m != 0 {
+		t.Errorf("Expected invalid arguments to stay unset, got %d and %d", *n, *m)
+	}
+	if source := p.GetArgs()[2].GetSource(); source.Kind != SourceNone {
+		t.Errorf("Expected no source of invalid argument, got %#v", source)
+	}
+
+	var parseErrors ParseErrors
+	var invalid InvalidArgumentError
+	if !errors.As(err, &parseErrors) || !parseErrors.As(&invalid) || invalid.Arg.GetLname() != "num" {
+		t.Errorf("Expected ParseErrors.As to find InvalidArgumentError of num, got %#v", invalid)
+	}
+	if !parseErrors.Is(parseErrors[1]) || parseErrors.Is(errors.New("other")) {
+		t.Error("Unexpected result of ParseErrors.Is")
+	}
+}
+
+// namedArg - implementation of Arg outside of the package, which has names only
+type namedArg struct {
+	Arg
+	sname, lname string
+}
+
+func (a namedArg) GetSname() string { return a.sname }
+func (a namedArg) GetLname() string { return a.lname }
+
+func TestArgumentErrorsMessages(t *testing.T) {
+	var nilArg *arg
+	tt := []struct {
+		err      error
+		expected string
+	}{
+		{MissingArgumentError{}, "required argument is missing"},
+		{MissingArgumentError{Arg: nilArg}, "required argument is missing"},
+		{MissingArgumentError{Arg: namedArg{sname: "n", lname: "num"}}, "[-n|--num] is required"},
+		{MissingArgumentError{Arg: namedArg{lname: "num"}}, "[--num] is required"},
+		{InvalidArgumentError{}, "invalid argument"},
+		{InvalidArgumentError{Arg: namedArg{sname: "n"}}, "[-n] is invalid"},
+		{InvalidArgumentError{Arg: namedArg{lname: "num"}, Err: errors.New("bad value")}, "bad value"},
+	}
+	for _, tc := range tt {
+		if msg := tc.err.Error(); msg != tc.expected {
+			t.Errorf("Expected %q, got %q", tc.expected, msg)
+		}
+	}
+}
+
+func TestErrorPosition(t *testing.T) {
+	p := NewParser("prog", "description")
+	p.Int("n", "num", nil)
+	p.String("s", "str", nil)
+	err := p.Parse([]string{"prog", "-s", "two words", "-n", "x"})
+	if err == nil {
+		t.Fatal("Parsing should fail")
+	}
+	if index := p.ErrorIndex(err); index != 4 {
+		t.Errorf("Expected error index 4, got %d", index)
+	}
+	if index := p.ErrorIndex(fmt.Errorf("wrapped: %w", err)); index != 4 {
+		t.Errorf("Expected error index 4 of wrapped error, got %d", index)
+	}
+	var invalidErr InvalidArgumentError
+	if errors.As(err, &invalidErr) {
+		t.Errorf("Expected error to be returned as it is unless errors are collected, got %#v", err)
+	}
+	expected := "[-n|--num] bad integer value [x]\n  prog -s 'two words' -n x\n                         ^\n"
+	if usage := p.Usage(err); !strings.HasPrefix(usage, expected) {
+		t.Errorf("Expected usage to begin with:\n%s\nactual:\n%s", expected, usage)
+	}
+
+	p = NewParser("prog", "description")
+	p.Int("n", "num", nil)
+	err = p.Parse([]string{"prog", "-n5x"})
+	if index := p.ErrorIndex(err); index != 1 {
+		t.Errorf("Expected error index of attached value 1, got %d", index)
+	}
+	err = p.Parse([]string{"prog", "-n"})
+	if index := p.ErrorIndex(err); index != 1 {
+		t.Errorf("Expected error index of argument without value 1, got %d", index)
+	}
+
+	errName := errors.New("bad name")
+	p = NewParser("prog", "description")
+	p.String("s", "str", &Options{Validate: func(args []string) error { return errName }})
+	err = p.Parse([]string{"prog", "-s", "x"})
+	if err != errName {
+		t.Errorf("Expected error of Validate to be returned as it is, got %#v", err)
+	}
+	if index := p.ErrorIndex(err); index != 2 {
+		t.Errorf("Expected error index of validated value 2, got %d", index)
+	}
+	if index := p.ErrorIndex(errors.New("other")); index != -1 {
+		t.Errorf("Expected error index -1, got %d", index)
+	}
+
+	p = NewParser("prog", "description")
+	p.Flag("v", "verbose", nil)
+	err = p.Parse([]string{"prog", "first", "-v", "second"})
+	expected = "unknown arguments first second\n  prog first -v second\n       ^^^^^    ^^^^^^\n"
+	if usage := p.Usage(err); !strings.HasPrefix(usage, expected) {
+		t.Errorf("Expected usage to begin with:\n%s\nactual:\n%s", expected, usage)
+	}
+
+	p = NewParser("prog", "description")
+	p.CollectErrors(true)
+	p.SetHelpWidth(30)
+	p.Int("n", "num", &Options{Required: true})
+	p.Float("f", "float", nil)
+	args := []string{"prog", "--aaaaaaaa", "--bbbbbbbb", "--cccccccc", "--float=y", "--dddddddd", "--eeeeeeee"}
+	err = p.Parse(args)
+	var invalid InvalidArgumentError
+	if !errors.As(err, &invalid) || invalid.Index != 4 {
+		t.Errorf("Expected InvalidArgumentError with index 4, got %#v", invalid)
+	}
+	expected = `7 errors occurred:
+  * [-f|--float] bad floating point value [y]
+      ... --float=y --dddddddd ...
+          ^^^^^^^^^
+  * [-n|--num] is required
+  * unknown argument --aaaaaaaa
+      ... --aaaaaaaa --bbbbbbbb ...
+          ^^^^^^^^^^
+  * unknown argument --bbbbbbbb
+      ... --bbbbbbbb --cccccccc ...
+          ^^^^^^^^^^
+  * unknown argument --cccccccc
+      ... --cccccccc --float=y ...
+          ^^^^^^^^^^
+  * unknown argument --dddddddd
+      ... --dddddddd --eeeeeeee
+          ^^^^^^^^^^
+  * unknown argument --eeeeeeee
+      ... --dddddddd --eeeeeeee
+                     ^^^^^^^^^^
+`
+	if usage := p.Usage(err); !strings.HasPrefix(usage, expected) {
+		t.Errorf("Expected usage to begin with:\n%s\nactual:\n%s", expected, usage)
+	}
+}
+
+// benchmarkParse - parses command line with n string arguments, each of them provided once
+func benchmarkParse(b *testing.B, n int) {
+	args := []string{"prog"}
+	for i := 0; i < n; i++ {
+		args = append(args, fmt.Sprintf("--arg%d", i), "value")
+	}
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		b.StopTimer()
+		p := NewParser("prog", "description")
+		for k := 0; k < n; k++ {
+			p.String("", fmt.Sprintf("arg%d", k), nil)
+		}
+		b.StartTimer()
+		if err := p.Parse(args); err != nil {
+			b.Fatal(err)
+		}
+	}
+}
+
+func BenchmarkParse10(b *testing.B) {
+	benchmarkParse(b, 10)
+}
+
+func BenchmarkParse100(b *testing.B) {
+	benchmarkParse(b, 100)
+}
+
+func BenchmarkParse1000(b *testing.B) {
+	benchmarkParse(b, 1000)
+}
+
+func TestEqualCharEdgeCases(t *testing.T) {
+	p := NewParser("prog", "description")
+	verbose := p.FlagCounter("v", "verbose", nil)
+	err := p.Parse([]string{"prog", "-vv=2"})
+	if err == nil || err.Error() != "[-v|--verbose] can only be present once when given a value" {
+		t.Errorf("Unexpected error %v", err)
+	}
+
+	p = NewParser("prog", "description")
+	verbose = p.FlagCounter("v", "verbose", nil)
+	if err := p.Parse([]string{"prog", "-v", "-v=3"}); err != nil || *verbose != 3 {
+		t.Errorf("Expected verbose 3, got %d with error %v", *verbose, err)
+	}
+
+	p = NewParser("prog", "description")
+	a := p.String("", "a", nil)
+	ab := p.String("", "a=b", nil)
+	if err := p.Parse([]string{"prog", "--a=b=c"}); err != nil || *a != "b=c" || *ab != "" {
+		t.Errorf("Expected a=%q and a=b=%q, got %q and %q with error %v", "b=c", "", *a, *ab, err)
+	}
+
+	p = NewParser("prog", "description")
+	p.Flag("v", "verbose", nil)
+	err = p.Parse([]string{"prog", "-vx=1", ""})
+	if err == nil || err.Error() != "unknown arguments -x=1 " {
+		t.Errorf("Unexpected error %q", err)
+	}
+}
+
+func TestMapArguments(t *testing.T) {
+	p := NewParser("prog", "description")
+	define := p.StringMap("D", "define", nil)
+	limits := p.IntMap("", "limit", &Options{DuplicateKeys: DuplicateKeyKeepFirst})
+	weights := p.FloatMap("w", "weight", &Options{Default: map[string]float64{"a": 1}})
+	err := p.Parse([]string{"prog", "--define=a=b", "-D", "c=", "--define", "a=x=y", "--limit", "cpu=2", "--limit=cpu=4"})
+	if err != nil {
+		t.Fatalf("Unexpected error %v", err)
+	}
+	if !reflect.DeepEqual(*define, map[string]string{"a": "x=y", "c": ""}) {
+		t.Errorf("Unexpected define %v", *define)
+	}
+	if !reflect.DeepEqual(*limits, map[string]int{"cpu": 2}) {
+		t.Errorf("Unexpected limit %v", *limits)
+	}
+	if !reflect.DeepEqual(*weights, map[string]float64{"a": 1}) {
+		t.Errorf("Unexpected weight %v", *weights)
+	}
+
+	failures := map[string][]string{
+		"[--limit] key [cpu] occurs more than once":  {"--limit", "cpu=1", "--limit", "cpu=2"},
+		"[--limit] bad integer value [x]":            {"--limit", "cpu=x"},
+		"[-w|--weight] bad floating point value [x]": {"-w", "a=x"},
+		"[-w|--weight] bad key=value pair [a]":       {"-w", "a"},
+		"[-w|--weight] bad key=value pair [=1]":      {"-w", "=1"},
+	}
+	for expected, args := range failures {
+		p := NewParser("prog", "description")
+		p.IntMap("", "limit", &Options{DuplicateKeys: DuplicateKeyError})
+		p.FloatMap("w", "weight", nil)
+		if err := p.Parse(append([]string{"prog"}, args...)); err == nil || err.Error() != expected {
+			t.Errorf("Expected error %q for %v, got %v", expected, args, err)
+		}
+	}
+
+	usage := p.Usage(nil)
+	if !strings.Contains(usage, "[-w|--weight <key>=<float> [-w|--weight <key>=<float> ...]]") ||
+		!strings.Contains(usage, "[--limit <key>=<integer> [--limit <key>=<integer> ...]]") {
+		t.Errorf("Unexpected usage:\n%s", usage)
+	}
+
+	spec, err := p.ExportSchema()
+	if err != nil {
+		t.Fatal(err)
+	}
+	built, err := NewParserFromSpec(spec, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := built.Parse([]string{"prog", "--limit", "cpu=2", "--limit", "cpu=3"}); err != nil {
+		t.Fatalf("Unexpected error %v", err)
+	}
+	if value := built.GetArg("limit").GetValue(); !reflect.DeepEqual(value, map[string]int{"cpu": 2}) {
+		t.Errorf("Unexpected limit %v", value)
+	}
+	if value := built.GetArg("weight").GetValue(); !reflect.DeepEqual(value, map[string]float64{"a": 1}) {
+		t.Errorf("Unexpected weight %v", value)
+	}
+}
+
+func TestAttachedShortValues(t *testing.T) {
+	p := NewParser("prog", "description")
+	extract := p.Flag("x", "extract", nil)
+	verbose := p.FlagCounter("v", "verbose", nil)
+	file := p.String("f", "file", nil)
+	jobs := p.Int("j", "jobs", nil)
+	output := p.String("o", "output", nil)
+	offset := p.Int("n", "offset", nil)
+	define := p.StringMap("D", "define", nil)
+	err := p.Parse([]string{"prog", "-xvfout.tar", "-j8", "-o=file.txt", "-n-5", "-Da=b", "-D=c=d", "-vv"})
+	if err != nil {
+		t.Fatalf("Unexpected error %v", err)
+	}
+	if !*extract || *verbose != 3 || *file != "out.tar" || *jobs != 8 || *output != "file.txt" || *offset != -5 {
+		t.Errorf("Unexpected values %v %v %q %v %q %v", *extract, *verbose, *file, *jobs, *output, *offset)
+	}
+	if !reflect.DeepEqual(*define, map[string]string{"a": "b", "c": "d"}) {
+		t.Errorf("Unexpected define %v", *define)
+	}
+	if source := p.GetArg("file").GetSource(); source.Index != 1 || source.Raw != "out.tar" {
+		t.Errorf("Unexpected source %v", source)
+	}
+
+	failures := map[string][]string{
+		"not enough arguments for -o|--output": {"-xo="},
+		"unknown arguments -y":                 {"-yxofile"},
+		"unknown arguments -y=x":               {"-xy=x"},
+		"[-v|--verbose] bad integer value [x]": {"-xv=x"},
+	}
+	for expected, args := range failures {
+		p := NewParser("prog", "description")
+		p.Flag("x", "extract", nil)
+		p.FlagCounter("v", "verbose", nil)
+		p.String("o", "output", nil)
+		if err := p.Parse(append([]string{"prog"}, args...)); err == nil || err.Error() != expected {
+			t.Errorf("Expected error %q for %v, got %v", expected, args, err)
+		}
+	}
+}
+
+func TestNonASCIIShortName(t *testing.T) {
+	p := NewParser("prog", "description")
+	verbose := p.FlagCounter("ü", "uber", nil)
+	name := p.String("ñ", "name", nil)
+	if err := p.Parse([]string{"prog", "-üü", "-ñjosé", "-ü"}); err != nil {
+		t.Fatalf("Unexpected error %v", err)
+	}
+	if *verbose != 3 || *name != "josé" {
+		t.Errorf("Unexpected values %v %q", *verbose, *name)
+	}
+	if !strings.Contains(p.Usage(nil), "-ñ|--name") {
+		t.Errorf("Expected short name in usage:\n%s", p.Usage(nil))
+	}
+
+	p = NewParser("prog", "description")
+	p.DeferErrors(true)
+	p.Flag("üü", "double", nil)
+	if err := p.Validate(); err == nil || err.Error() != "unable to add Flag: short name must not exceed 1 character" {
+		t.Errorf("Unexpected error %v", err)
+	}
+}
+
+func TestOrdering(t *testing.T) {
+	newParser := func() (*Parser, *bool, *string) {
+		p := NewParser("prog", "description")
+		verbose := p.Flag("v", "verbose", nil)
+		cmd := p.NewCommand("exec", "exec description")
+		output := cmd.String("o", "output", nil)
+		cmd.NewCommand("sub", "sub description")
+		p.NewCommand("run", "run description")
+		return p, verbose, output
+	}
+
+	p, verbose, _ := newParser()
+	if err := p.Parse([]string{"prog", "run", "-v"}); err != nil || !*verbose {
+		t.Errorf("Expected verbose with interspersed ordering, got %v with error %v", *verbose, err)
+	}
+
+	p, verbose, _ = newParser()
+	p.SetOrdering(StopAtNonOption)
+	err := p.Parse([]string{"prog", "run", "-v", "file", "-v"})
+	if err == nil || err.Error() != "unknown arguments file -v" || !*verbose {
+		t.Errorf("Expected verbose and unknown arguments, got %v with error %v", *verbose, err)
+	}
+
+	p, verbose, output := newParser()
+	p.GetCommands()[0].SetOrdering(StopAtNonOption)
+	err = p.Parse([]string{"prog", "exec", "sub", "-o", "out", "--", "-v"})
+	if err == nil || err.Error() != "unknown arguments -v" || *verbose || *output != "out" {
+		t.Errorf("Expected output and unknown arguments, got %v %q with error %v", *verbose, *output, err)
+	}
+
+	p, verbose, _ = newParser()
+	p.GetCommands()[0].SetOrdering(StopAtNonOption)
+	if err := p.Parse([]string{"prog", "run", "x", "-v"}); err == nil || err.Error() != "unknown arguments x" || !*verbose {
+		t.Errorf("Expected ordering of run to be interspersed, got %v with error %v", *verbose, err)
+	}
+	if p.GetCommands()[0].getOrdering() != StopAtNonOption || p.getOrdering() != Interspersed {
+		t.Errorf("Unexpected ordering %v, %v", p.GetCommands()[0].getOrdering(), p.getOrdering())
+	}
+}
+
+func TestParseKnownArgs(t *testing.T) {
+	p := NewParser("prog", "description")
+	verbose := p.Flag("v", "verbose", nil)
+	cmd := p.NewCommand("wrap", "wrap description")
+	output := cmd.String("o", "output", nil)
+	rest, err := p.ParseKnownArgs([]string{"prog", "wrap", "--color=auto", "-vx", "file", "-o", "out", "", "--", "-z"})
+	if err != nil {
+		t.Fatalf("Unexpected error %v", err)
+	}
+	expected := []string{"--color=auto", "-x", "file", "", "--", "-z"}
+	if !reflect.DeepEqual(rest, expected) || !*verbose || *output != "out" {
+		t.Errorf("Expected rest %q, got %q with %v %q", expected, rest, *verbose, *output)
+	}
+
+	p = NewParser("prog", "description")
+	p.Int("n", "num", nil)
+	p.String("s", "str", &Options{Required: true})
+	rest, err = p.ParseKnownArgs([]string{"prog", "-n", "x", "other"})
+	if err == nil || err.Error() != "[-n|--num] bad integer value [x]" || rest != nil {
+		t.Errorf("Unexpected rest %q with error %v", rest, err)
+	}
+
+	p = NewParser("prog", "description")
+	p.CollectErrors(true)
+	p.Int("n", "num", nil)
+	p.String("s", "str", &Options{Required: true})
+	rest, err = p.ParseKnownArgs([]string{"prog", "-n", "x", "other"})
+	if err == nil || err.Error() != "2 errors occurred:\n  * [-n|--num] bad integer value [x]\n  * [-s|--str] is required" {
+		t.Errorf("Unexpected rest %q with error %v", rest, err)
+	}
+
+	p = NewParser("prog", "description")
+	p.SetOrdering(StopAtNonOption)
+	verbose = p.Flag("v", "verbose", nil)
+	rest, err = p.ParseKnownArgs([]string{"prog", "-v", "tool", "-v", "--help"})
+	if err != nil || !reflect.DeepEqual(rest, []string{"tool", "-v", "--help"}) || !*verbose {
+		t.Errorf("Unexpected rest %q with error %v", rest, err)
+	}
+}
+
+func TestPassThrough(t *testing.T) {
+	newParser := func() (*Parser, *bool, *[]string) {
+		p := NewParser("prog", "description")
+		p.ExitOnHelp(false)
+		verbose := p.Flag("v", "verbose", &Options{Default: true})
+		run := p.NewCommand("run", "run description")
+		run.NewCommand("tool", "tool description")
+		return p, verbose, run.EnablePassThrough()
+	}
+
+	p, verbose, rest := newParser()
+	args := []string{"prog", "run", "tool", "--help", "-v", "", "--", "a=b"}
+	if err := p.Parse(args); err != nil {
+		t.Fatalf("Unexpected error %v", err)
+	}
+	if !reflect.DeepEqual(*rest, args[2:]) || !*verbose {
+		t.Errorf("Expected captured %q, got %q with verbose %v", args[2:], *rest, *verbose)
+	}
+	run := p.GetCommands()[0]
+	if !run.Happened() || run.GetCommands()[0].Happened() || !reflect.DeepEqual(run.GetPassThrough(), args[2:]) {
+		t.Errorf("Unexpected commands state %v %v %q", run.Happened(), run.GetCommands()[0].Happened(), run.GetPassThrough())
+	}
+	if p.GetPassThrough() != nil {
+		t.Errorf("Expected no pass-through of parser, got %q", p.GetPassThrough())
+	}
+
+	p, _, rest = newParser()
+	if err := p.Parse([]string{"prog", "run"}); err != nil || len(*rest) != 0 {
+		t.Errorf("Expected nothing captured, got %q with error %v", *rest, err)
+	}
+	if usage := run.Usage(nil); !strings.HasPrefix(usage, "usage: prog run <Command> [-h|--help] [-v|--verbose] [<args> ...]\n") {
+		t.Errorf("Unexpected usage:\n%s", usage)
+	}
+
+	p, _, _ = newParser()
+	expected := []string{"command prog run: pass-through command has sub-commands, which are never selected"}
+	if problems := p.Lint(); len(problems) != 1 || problems[0].Error() != expected[0] {
+		t.Errorf("Expected problems %q, got %v", expected, problems)
+	}
+	spec, err := p.ExportSchema()
+	if err != nil {
+		t.Fatal(err)
+	}
+	built, err := NewParserFromSpec(spec, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := built.Parse([]string{"prog", "run", "-x"}); err != nil ||
+		!reflect.DeepEqual(built.GetCommands()[0].GetPassThrough(), []string{"-x"}) {
+		t.Errorf("Unexpected captured %q with error %v", built.GetCommands()[0].GetPassThrough(), err)
+	}
+}
@@ -20,7 +20,9 @@ type arg struct {
 	filePerm os.FileMode // File permissions to set a file
 	selector *[]string   // Used in Selector type to allow to choose only one from list of options
 	parent   *Command    // Used to get access to specific Command
-	eqChar   bool        // This is used if the command is passed in with an equals char as a seperator
+	negated  bool        // This is used if negatable flag is passed in with "no-" prefix
+	nullable interface{} // Pointer to pointer which is set to result once argument is parsed
+	source   Source      // Where the value of argument came from
 }
 
 // Arg interface provides exporting of arg structure, while exposing it
@@ -28,6 +30,76 @@ type Arg interface {
 	GetOpts() *Options
 	GetSname() string
 	GetLname() string
+	GetParsed() bool
+	GetSource() Source
+	GetType() string
+	GetArity() int
+	GetSelector() []string
+	GetValue() interface{}
+	GetParent() *Command
+}
+
+// SourceKind tells where the value of an argument came from
+type SourceKind int
+
+const (
+	// SourceNone means that argument has no value: it was not provided and has no default
+	SourceNone SourceKind = iota
+	// SourceDefault means that argument was set to Options.Default
+	SourceDefault
+	// SourceCommandLine means that argument was provided on command line
+	SourceCommandLine
+	// SourceEnvironment means that argument was set from environment variable with Command.SetValue
+	SourceEnvironment
+	// SourceConfig means that argument was set from configuration file with Command.SetValue
+	SourceConfig
+)
+
+func (k SourceKind) String() string {
+	switch k {
+	case SourceDefault:
+		return "default"
+	case SourceCommandLine:
+		return "command line"
+	case SourceEnvironment:
+		return "environment"
+	case SourceConfig:
+		return "config"
+	}
+	return "none"
+}
+
+// Source describes where the value of an argument came from
+type Source struct {
+	Kind SourceKind
+	// Index is the position of the token in the slice passed to Parser.Parse, for SourceCommandLine only.
+	// If argument was provided multiple times, it is the position of the last occurrence.
+	Index int
+	// Raw is the string the value was parsed from: the value token for command line (or the argument itself
+	// if it takes no value) and the default value formatted with %v for default
+	Raw string
+	// Name is the name of environment variable for SourceEnvironment, or the key in configuration file
+	// for SourceConfig
+	Name string
+	// Path is the path of configuration file and Line is the line the value is at (if known), for SourceConfig only
+	Path string
+	Line int
+}
+
+func (s Source) String() string {
+	switch s.Kind {
+	case SourceCommandLine:
+		return fmt.Sprintf("%s (argument %d: %q)", s.Kind, s.Index, s.Raw)
+	case SourceEnvironment:
+		return fmt.Sprintf("%s ($%s: %q)", s.Kind, s.Name, s.Raw)
+	case SourceConfig:
+		location := s.Path
+		if s.Line > 0 {
+			location += ":" + strconv.Itoa(s.Line)
+		}
+		return fmt.Sprintf("%s (%s %s: %q)", s.Kind, location, s.Name, s.Raw)
+	}
+	return s.Kind.String()
 }
 
 func (o arg) GetOpts() *Options {
@@ -42,125 +114,98 @@ func (o arg) GetLname() string {
 	return o.lname
 }
 
-type help struct{}
+// GetParsed tells whether argument was explicitly provided on command line (or set with Command.SetValue).
+// It is false if argument was not provided, even if it has a default value.
+func (o arg) GetParsed() bool {
+	return o.parsed
+}
 
-// checkLongName if long argumet present.
-// checkLongName - returns the argumet's long name number of occurrences and error.
-// For long name return value is 0 or 1.
-func (o *arg) checkLongName(argument string) int {
-	// Check for long name only if not empty
-	if o.lname != "" {
-		// If argument begins with "--" and next is not "-" then it is a long name
-		if len(argument) > 2 && strings.HasPrefix(argument, "--") && argument[2] != '-' {
-			if argument[2:] == o.lname {
-				return 1
-			}
+// GetType returns type of argument, which is one of: "flag", "counter", "string", "selector", "int", "float",
+// "file", "stringList", "intList", "floatList", "fileList", "stringMap", "intMap", "floatMap", "help", "helpAll"
+// or "version"
+func (o arg) GetType() string {
+	switch o.result.(type) {
+	case *bool:
+		return "flag"
+	case *int:
+		if o.size == 1 {
+			return "counter"
 		}
-	}
-
-	return 0
-}
-
-// checkShortName if argumet present.
-// checkShortName - returns the argumet's short name number of occurrences and error.
-// For shorthand argument - 0 if there is no occurrences, or count of occurrences.
-// Shorthand argument with parametr, mast be the only or last in the argument string.
-func (o *arg) checkShortName(argument string) (int, error) {
-	// Check for short name only if not empty
-	if o.sname != "" {
-
-		// If argument begins with "-" and next is not "-" then it is a short name
-		if len(argument) > 1 && strings.HasPrefix(argument, "-") && argument[1] != '-' {
-			count := strings.Count(argument[1:], o.sname)
-			switch {
-			// For args with size 1 (Flag,FlagCounter) multiple shorthand in one argument are allowed
-			case o.size == 1:
-				return count, nil
-			// For args with o.size > 1, shorthand argument is allowed only to complete the sequence of arguments combined into one
-			case o.size > 1:
-				if count > 1 {
-					return count, fmt.Errorf("[%s] argument: The parameter must follow", o.name())
-				}
-				if strings.HasSuffix(argument[1:], o.sname) {
-					return count, nil
-				}
-			//if o.size < 1 - it is an error
-			default:
-				return 0, fmt.Errorf("Argument's size < 1 is not allowed")
-			}
+		return "int"
+	case *float64:
+		return "float"
+	case *string:
+		if o.selector != nil {
+			return "selector"
 		}
+		return "string"
+	case *os.File:
+		return "file"
+	case *[]string:
+		return "stringList"
+	case *[]int:
+		return "intList"
+	case *[]float64:
+		return "floatList"
+	case *[]os.File:
+		return "fileList"
+	case *map[string]string:
+		return "stringMap"
+	case *map[string]int:
+		return "intMap"
+	case *map[string]float64:
+		return "floatMap"
+	case *help:
+		return "help"
+	case *helpAll:
+		return "helpAll"
+	case *version:
+		return "version"
 	}
+	return ""
+}
 
-	return 0, nil
+// GetArity returns number of values argument takes after its name: 0 for flags and 1 for all other arguments
+func (o arg) GetArity() int {
+	return o.size - 1
 }
 
-// check if argumet present.
-// check - returns the argumet's number of occurrences and error.
-// For long name return value is 0 or 1.
-// For shorthand argument - 0 if there is no occurrences, or count of occurrences.
-// Shorthand argument with parametr, mast be the only or last in the argument string.
-func (o *arg) check(argument string) (int, error) {
-	rez := o.checkLongName(argument)
-	if rez > 0 {
-		return rez, nil
+// GetSelector returns list of allowed values of Selector argument, or nil for other arguments
+func (o arg) GetSelector() []string {
+	if o.selector == nil {
+		return nil
 	}
-
-	return o.checkShortName(argument)
+	return *o.selector
 }
 
-func (o *arg) reduceLongName(position int, args *[]string) {
-	argument := (*args)[position]
-	// Check for long name only if not empty
-	if o.lname != "" {
-		// If argument begins with "--" and next is not "-" then it is a long name
-		if len(argument) > 2 && strings.HasPrefix(argument, "--") && argument[2] != '-' {
-			if o.eqChar {
-				splitInd := strings.LastIndex(argument, "=")
-				equalArg := []string{argument[:splitInd], argument[splitInd+1:]}
-				argument = equalArg[0]
-			}
-			if argument[2:] == o.lname {
-				for i := position; i < position+o.size; i++ {
-					(*args)[i] = ""
-				}
-			}
-		}
+// GetValue returns current value of argument, such as int for Int or []string for StringList.
+// Returns nil for help and version arguments.
+func (o arg) GetValue() interface{} {
+	switch o.result.(type) {
+	case *help, *helpAll, *version:
+		return nil
 	}
+	return reflect.ValueOf(o.result).Elem().Interface()
 }
 
-func (o *arg) reduceShortName(position int, args *[]string) {
-	argument := (*args)[position]
-	// Check for short name only if not empty
-	if o.sname != "" {
-		// If argument begins with "-" and next is not "-" then it is a short name
-		if len(argument) > 1 && strings.HasPrefix(argument, "-") && argument[1] != '-' {
-			// For args with size 1 (Flag,FlagCounter) we allow multiple shorthand in one
-			if o.size == 1 {
-				if strings.Contains(argument[1:], o.sname) {
-					(*args)[position] = strings.Replace(argument, o.sname, "", -1)
-					if (*args)[position] == "-" {
-						(*args)[position] = ""
-					}
-					if o.eqChar {
-						(*args)[position] = ""
-					}
-				}
-				// For all other types it must be separate argument
-			} else {
-				if argument[1:] == o.sname {
-					for i := position; i < position+o.size; i++ {
-						(*args)[i] = ""
-					}
-				}
-			}
-		}
-	}
+// GetParent returns Command the argument belongs to
+func (o arg) GetParent() *Command {
+	return o.parent
 }
 
-// clear out already used argument from args at position
-func (o *arg) reduce(position int, args *[]string) {
-	o.reduceLongName(position, args)
-	o.reduceShortName(position, args)
+// GetSource tells where the value of argument came from
+func (o arg) GetSource() Source {
+	return o.source
+}
+
+type help struct{}
+
+type helpAll struct{}
+
+// isNegatable - tells whether flag can be switched off with "no-" prefix
+func (o *arg) isNegatable() bool {
+	_, isBool := o.result.(*bool)
+	return isBool && o.opts != nil && o.opts.Negatable
 }
 
 func (o *arg) parseInt(args []string, argCount int) error {
@@ -187,8 +232,22 @@ func (o *arg) parseInt(args []string, argCount int) error {
 }
 
 func (o *arg) parseBool(args []string) error {
-	//data of bool type is for Flag argument
-	*o.result.(*bool) = true
+	//data of bool type is for Flag argument, which can be given explicit value as in --flag=false
+	value := !o.negated
+	if len(args) > 0 {
+		if o.negated {
+			return fmt.Errorf("[%s] negation does not take a value", o.name())
+		}
+		switch strings.ToLower(args[0]) {
+		case "true", "1", "yes":
+			value = true
+		case "false", "0", "no":
+			value = false
+		default:
+			return fmt.Errorf("[%s] bad boolean value [%s]", o.name(), args[0])
+		}
+	}
+	*o.result.(*bool) = value
 	o.parsed = true
 	return nil
 }
@@ -248,7 +307,7 @@ func (o *arg) parseFile(args []string) error {
 		return fmt.Errorf("[%s] followed by too many arguments", o.name())
 	}
 
-	f, err := os.OpenFile(args[0], o.fileFlag, o.filePerm)
+	f, err := o.openFile(args[0])
 	if err != nil {
 		return err
 	}
@@ -316,7 +375,7 @@ func (o *arg) parseFileList(args []string) error {
 	case len(args) > 1:
 		return fmt.Errorf("[%s] followed by too many arguments", o.name())
 	}
-	f, err := os.OpenFile(args[0], o.fileFlag, o.filePerm)
+	f, err := o.openFile(args[0])
 	if err != nil {
 		//if one of FileList's file opening have been failed, close all other in this list
 		errs := make([]string, 0, len(*o.result.(*[]os.File)))
@@ -342,11 +401,63 @@ func (o *arg) parseFileList(args []string) error {
 var exit func(int) = os.Exit
 var print func(...interface{}) (int, error) = fmt.Println
 
+func (o *arg) parseMap(args []string) error {
+	//data of map type is for StringMap, IntMap and FloatMap arguments with set of key=value parameters
+	switch {
+	case len(args) < 1:
+		return fmt.Errorf("[%s] must be followed by key=value pair", o.name())
+	case len(args) > 1:
+		return fmt.Errorf("[%s] followed by too many arguments", o.name())
+	}
+	eq := strings.Index(args[0], "=")
+	if eq < 1 {
+		return fmt.Errorf("[%s] bad key=value pair [%s]", o.name(), args[0])
+	}
+	key, raw := args[0][:eq], args[0][eq+1:]
+
+	var value interface{} = raw
+	switch o.result.(type) {
+	case *map[string]int:
+		val, err := strconv.Atoi(raw)
+		if err != nil {
+			return fmt.Errorf("[%s] bad integer value [%s]", o.name(), raw)
+		}
+		value = val
+	case *map[string]float64:
+		val, err := strconv.ParseFloat(raw, 64)
+		if err != nil {
+			return fmt.Errorf("[%s] bad floating point value [%s]", o.name(), raw)
+		}
+		value = val
+	}
+
+	result := reflect.ValueOf(o.result).Elem()
+	if result.MapIndex(reflect.ValueOf(key)).IsValid() && o.opts != nil {
+		switch o.opts.DuplicateKeys {
+		case DuplicateKeyKeepFirst:
+			o.parsed = true
+			return nil
+		case DuplicateKeyError:
+			return fmt.Errorf("[%s] key [%s] occurs more than once", o.name(), key)
+		}
+	}
+	result.SetMapIndex(reflect.ValueOf(key), reflect.ValueOf(value))
+	o.parsed = true
+	return nil
+}
+
 func (o *arg) parseSomeType(args []string, argCount int) error {
 	var err error
 	switch o.result.(type) {
 	case *help:
-		print(o.parent.Help(nil))
+		o.parent.printMessage(o.parent.Help(nil))
+		if o.parent.exitOnHelp {
+			exit(0)
+		}
+	case *version:
+		o.parent.printVersion()
+	case *helpAll:
+		o.parent.printMessage(o.parent.usageAll())
 		if o.parent.exitOnHelp {
 			exit(0)
 		}
@@ -369,6 +480,8 @@ func (o *arg) parseSomeType(args []string, argCount int) error {
 		err = o.parseFloatList(args)
 	case *[]os.File:
 		err = o.parseFileList(args)
+	case *map[string]string, *map[string]int, *map[string]float64:
+		err = o.parseMap(args)
 	default:
 		err = fmt.Errorf("unsupported type [%t]", o.result)
 	}
@@ -388,7 +501,14 @@ func (o *arg) parse(args []string, argCount int) error {
 			return err
 		}
 	}
-	return o.parseSomeType(args, argCount)
+	if err := o.parseSomeType(args, argCount); err != nil {
+		return err
+	}
+	// Nullable arguments point to the result only once it is provided
+	if o.parsed && o.nullable != nil {
+		reflect.ValueOf(o.nullable).Elem().Set(reflect.ValueOf(o.result))
+	}
+	return nil
 }
 
 func (o *arg) name() string {
@@ -403,30 +523,54 @@ func (o *arg) name() string {
 	return name
 }
 
+// usageName - returns name of argument as shown in help message, e.g. -c|--[no-]cache for negatable flag
+func (o *arg) usageName() string {
+	if !o.isNegatable() {
+		return o.name()
+	}
+	if o.sname == "" {
+		return "--[no-]" + o.lname
+	}
+	return "-" + o.sname + "|" + "--[no-]" + o.lname
+}
+
 func (o *arg) usage() string {
+	return o.styledUsage(nil)
+}
+
+// styledUsage - returns usage of argument highlighted with provided color theme
+func (o *arg) styledUsage(theme *ColorTheme) string {
 	var result string
-	result = o.name()
+	required := o.opts != nil && o.opts.Required
+	if required {
+		result = theme.paint("required", o.usageName())
+	} else {
+		result = theme.paint("flag", o.usageName())
+	}
 	switch o.result.(type) {
 	case *bool:
 		break
 	case *int:
-		result = result + " <integer>"
+		result = result + " " + theme.paint("metavar", "<integer>")
 	case *float64:
-		result = result + " <float>"
+		result = result + " " + theme.paint("metavar", "<float>")
 	case *string:
 		if o.selector != nil {
-			result = result + " (" + strings.Join(*o.selector, "|") + ")"
+			result = result + " " + theme.paint("metavar", "("+strings.Join(*o.selector, "|")+")")
 		} else {
-			result = result + " \"<value>\""
+			result = result + " " + theme.paint("metavar", "\"<value>\"")
 		}
 	case *os.File:
-		result = result + " <file>"
+		result = result + " " + theme.paint("metavar", "<file>")
 	case *[]string:
-		result = result + " \"<value>\"" + " [" + result + " \"<value>\" ...]"
+		result = result + " " + theme.paint("metavar", "\"<value>\"") + " [" + result + " " + theme.paint("metavar", "\"<value>\"") + " ...]"
+	case *map[string]string, *map[string]int, *map[string]float64:
+		metavar := map[string]string{"stringMap": "<key>=<value>", "intMap": "<key>=<integer>", "floatMap": "<key>=<float>"}[o.GetType()]
+		result = result + " " + theme.paint("metavar", metavar) + " [" + result + " " + theme.paint("metavar", metavar) + " ...]"
 	default:
 		break
 	}
-	if o.opts == nil || o.opts.Required == false {
+	if !required {
 		result = "[" + result + "]"
 	}
 	return result
@@ -443,11 +587,20 @@ func (o *arg) getHelpMessage() string {
 	return message
 }
 
+// openFile - opens file with file mode and permissions of the argument. Parser in dry mode, which only checks
+// that command line can be parsed (see validateExample), neither opens nor creates files
+func (o *arg) openFile(name string) (*os.File, error) {
+	if o.parent != nil && o.parent.root().dry {
+		return new(os.File), nil
+	}
+	return os.OpenFile(name, o.fileFlag, o.filePerm)
+}
+
 // setDefaultFile - gets default os.File object based on provided default filename string
 func (o *arg) setDefaultFile() error {
 	// In case of File we should get string as default value
 	if v, ok := o.opts.Default.(string); ok {
-		f, err := os.OpenFile(v, o.fileFlag, o.filePerm)
+		f, err := o.openFile(v)
 		if err != nil {
 			return err
 		}
@@ -465,7 +618,7 @@ func (o *arg) setDefaultFiles() error {
 	if fileNames, ok := o.opts.Default.([]string); ok {
 		files = make([]os.File, 0, len(fileNames))
 		for _, v := range fileNames {
-			f, err := os.OpenFile(v, o.fileFlag, o.filePerm)
+			f, err := o.openFile(v)
 			if err != nil {
 				//if one of FileList's file opening have been failed, close all other in this list
 				errs := make([]string, 0, len(*o.result.(*[]os.File)))
@@ -490,14 +643,37 @@ func (o *arg) setDefaultFiles() error {
 	return nil
 }
 
+// checkDefaultType - checks that default value can be assigned to the result of argument
+func (o *arg) checkDefaultType() error {
+	switch o.result.(type) {
+	case *os.File:
+		if _, ok := o.opts.Default.(string); !ok {
+			return fmt.Errorf("cannot use default type [%T] as value of pointer with type [*string]", o.opts.Default)
+		}
+	case *[]os.File:
+		if _, ok := o.opts.Default.([]string); !ok {
+			return fmt.Errorf("cannot use default type [%T] as value of pointer with type [*[]string]", o.opts.Default)
+		}
+	case *help, *helpAll, *version:
+		return fmt.Errorf("cannot use default value with [%T]", o.result)
+	default:
+		if reflect.TypeOf(o.result) != reflect.PtrTo(reflect.TypeOf(o.opts.Default)) {
+			return fmt.Errorf("cannot use default type [%T] as value of pointer with type [%T]", o.opts.Default, o.result)
+		}
+	}
+	return nil
+}
+
 // setDefault - if no value getted for specific argument, set default value, if provided
 func (o *arg) setDefault() error {
-	// Only set default if it was not parsed, and default value was defined
-	if !o.parsed && o.opts != nil && o.opts.Default != nil {
+	// Only set default if it was not parsed, and default value was defined.
+	// Nullable arguments stay nil when not provided, so their default value is ignored
+	if !o.parsed && o.nullable == nil && o.opts != nil && o.opts.Default != nil {
 		switch o.result.(type) {
-		case *bool, *int, *float64, *string, *[]bool, *[]int, *[]float64, *[]string:
-			if reflect.TypeOf(o.result) != reflect.PtrTo(reflect.TypeOf(o.opts.Default)) {
-				return fmt.Errorf("cannot use default type [%T] as value of pointer with type [%T]", o.opts.Default, o.result)
+		case *bool, *int, *float64, *string, *[]bool, *[]int, *[]float64, *[]string,
+			*map[string]string, *map[string]int, *map[string]float64:
+			if err := o.checkDefaultType(); err != nil {
+				return err
 			}
 			reflect.ValueOf(o.result).Elem().Set(reflect.ValueOf(o.opts.Default))
 
@@ -510,6 +686,7 @@ func (o *arg) setDefault() error {
 				return err
 			}
 		}
+		o.source = Source{Kind: SourceDefault, Raw: fmt.Sprint(o.opts.Default)}
 	}
 
 	return nil
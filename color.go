@@ -0,0 +1,137 @@
+package argparse
+
+import (
+	"fmt"
+	"io"
+	"os"
+)
+
+// ColorTheme defines ANSI SGR parameters (such as "1;32" for bold green) used to highlight parts of help and
+// error messages. Empty value leaves corresponding part uncolored.
+type ColorTheme struct {
+	Heading  string // Section headings, such as "usage:" and "Arguments:"
+	Command  string // Names of commands
+	Flag     string // Names of arguments
+	Metavar  string // Placeholders of argument values, such as <integer>
+	Required string // Names of required arguments
+	Error    string // Error messages
+}
+
+// DefaultColorTheme is a color theme that can be used with Command.SetColor
+var DefaultColorTheme = ColorTheme{
+	Heading:  "1",
+	Command:  "1;36",
+	Flag:     "32",
+	Metavar:  "33",
+	Required: "1;32",
+	Error:    "1;31",
+}
+
+// style - returns SGR parameters for kind of text, nil theme has no styles
+func (t *ColorTheme) style(kind string) string {
+	if t == nil {
+		return ""
+	}
+	switch kind {
+	case "heading":
+		return t.Heading
+	case "command":
+		return t.Command
+	case "flag":
+		return t.Flag
+	case "metavar":
+		return t.Metavar
+	case "required":
+		return t.Required
+	case "error":
+		return t.Error
+	}
+	return ""
+}
+
+// paint - wraps text into escape sequences of specified kind
+func (t *ColorTheme) paint(kind string, text string) string {
+	code := t.style(kind)
+	if code == "" || text == "" {
+		return text
+	}
+	return "\x1b[" + code + "m" + text + "\x1b[0m"
+}
+
+// SetOutput sets the writer which help messages of this Command and all its sub-commands are printed to.
+// If not set, messages are printed to standard output.
+func (o *Command) SetOutput(w io.Writer) {
+	o.output = w
+}
+
+// SetColor enables colored help and error messages of this Command and all its sub-commands using provided theme.
+// Passing nil disables colors. Colors are used only if output (see Command.SetOutput) is a terminal
+// and NO_COLOR environment variable is not set, unless colors are forced with Command.ForceColor.
+func (o *Command) SetColor(theme *ColorTheme) {
+	o.colorTheme = theme
+}
+
+// ForceColor makes this Command and all its sub-commands use colors set with Command.SetColor
+// regardless of output type and NO_COLOR environment variable
+func (o *Command) ForceColor(b bool) {
+	o.forceColor = b
+}
+
+// getOutput - returns output of this Command, inherited from its parents. Returns nil if output was not set.
+func (o *Command) getOutput() io.Writer {
+	for current := o; current != nil; current = current.parent {
+		if current.output != nil {
+			return current.output
+		}
+	}
+	return nil
+}
+
+// getColorTheme - returns color theme of this Command if colors should be used, otherwise nil
+func (o *Command) getColorTheme() *ColorTheme {
+	var theme *ColorTheme
+	force := false
+	for current := o; current != nil; current = current.parent {
+		if theme == nil {
+			theme = current.colorTheme
+		}
+		force = force || current.forceColor
+	}
+	if theme == nil || force {
+		return theme
+	}
+	if os.Getenv("NO_COLOR") != "" {
+		return nil
+	}
+	output := o.getOutput()
+	if output == nil {
+		output = os.Stdout
+	}
+	if !isTerminal(output) {
+		return nil
+	}
+	return theme
+}
+
+// isTerminal - tells whether writer is a character device, such as terminal.
+// It is a variable so it can be replaced in tests
+var isTerminal = func(w io.Writer) bool {
+	f, ok := w.(*os.File)
+	if !ok {
+		return false
+	}
+	stat, err := f.Stat()
+	if err != nil {
+		return false
+	}
+	return stat.Mode()&os.ModeCharDevice != 0
+}
+
+// printMessage - prints message followed by a new line to the output of this Command
+func (o *Command) printMessage(msg string) {
+	if w := o.getOutput(); w != nil {
+		fmt.Fprintln(w, msg)
+		return
+	}
+	print(msg)
+}
@@ -3,6 +3,7 @@ package argparse
 import (
 	"fmt"
 	"strings"
+	"unicode/utf8"
 )
 
 func (o *Command) help(sname, lname string) {
@@ -30,7 +31,7 @@ func (o *Command) addArg(a *arg) error {
 		return fmt.Errorf("long name should be provided")
 	}
 	// short name could be provided and must not exceed 1 character
-	if len(a.sname) > 1 {
+	if utf8.RuneCountInString(a.sname) > 1 {
 		return fmt.Errorf("short name must not exceed 1 character")
 	}
 	// Search parents for overlapping commands and fail if any
@@ -53,6 +54,47 @@ func (o *Command) addArg(a *arg) error {
 	return nil
 }
 
+// addArgOrFail - adds argument, on failure panics or collects the error in deferred errors mode
+func (o *Command) addArgOrFail(kind string, a *arg) {
+	if err := o.addArg(a); err != nil {
+		err = fmt.Errorf("unable to add %s: %s", kind, err.Error())
+		root := o.root()
+		if !root.deferErrors {
+			panic(err)
+		}
+		root.defErrors = append(root.defErrors, err)
+	}
+}
+
+// parseHelpCommand - resolves command path from the leading non-argument values
+// and prints help message of that command
+func (o *Command) parseHelpCommand(args *[]string) error {
+	target := o.parent
+	for i := 0; i < len(*args) && !strings.HasPrefix((*args)[i], "-"); i++ {
+		name := (*args)[i]
+		var next *Command
+		for _, c := range target.commands {
+			if c.name == name {
+				next = c
+			}
+		}
+		if next == nil {
+			return newUnknownCommandError(target, name)
+		}
+		target = next
+		o.consume(args, i, 1)
+	}
+
+	// Help of the parent is redirected to the happened command, so hide this command while printing
+	o.happened = false
+	o.printMessage(target.Help(nil))
+	o.happened = true
+	if o.exitOnHelp {
+		exit(0)
+	}
+	return nil
+}
+
 //parseSubCommands - Parses subcommands if any
 func (o *Command) parseSubCommands(args *[]string) error {
 	if o.commands != nil && len(o.commands) > 0 {
@@ -66,67 +108,103 @@ func (o *Command) parseSubCommands(args *[]string) error {
 			if err != nil {
 				return err
 			}
+			if v.happened {
+				break
+			}
 		}
 	}
 	return nil
 }
 
-//parseArguments - Parses arguments
-func (o *Command) parseArguments(args *[]string) error {
-	// Iterate over the args
-	for i := 0; i < len(o.args); i++ {
-		oarg := o.args[i]
-		for j := 0; j < len(*args); j++ {
-			arg := (*args)[j]
-			if arg == "" {
-				continue
-			}
-			if strings.Contains(arg, "=") {
-				splitInd := strings.LastIndex(arg, "=")
-				equalArg := []string{arg[:splitInd], arg[splitInd+1:]}
-				if cnt, err := oarg.check(equalArg[0]); err != nil {
-					return err
-				} else if cnt > 0 {
-					if equalArg[1] == "" {
-						return fmt.Errorf("not enough arguments for %s", oarg.name())
-					}
-					oarg.eqChar = true
-					oarg.size = 1
-					currArg := []string{equalArg[1]}
-					err := oarg.parse(currArg, cnt)
-					if err != nil {
-						return err
-					}
-					oarg.reduce(j, args)
-					continue
-				}
-			}
-			if cnt, err := oarg.check(arg); err != nil {
-				return err
-			} else if cnt > 0 {
-				if len(*args) < j+oarg.size {
-					return fmt.Errorf("not enough arguments for %s", oarg.name())
-				}
-				err := oarg.parse((*args)[j+1:j+oarg.size], cnt)
-				if err != nil {
-					return err
-				}
-				oarg.reduce(j, args)
-				continue
-			}
+// subCommandHappened - tells whether any sub-command of this Command was selected
+func (o *Command) subCommandHappened() bool {
+	for _, c := range o.commands {
+		if c.happened {
+			return true
 		}
+	}
+	return false
+}
 
-		// Check if arg is required and not provided
-		if oarg.opts != nil && oarg.opts.Required && !oarg.parsed {
-			return fmt.Errorf("[%s] is required", oarg.name())
-		}
+// root - returns top level command
+func (o *Command) root() *Command {
+	current := o
+	for current.parent != nil {
+		current = current.parent
+	}
+	return current
+}
 
-		// Check for argument default value and if provided try to type cast and assign
-		if oarg.opts != nil && oarg.opts.Default != nil && !oarg.parsed {
-			err := oarg.setDefault()
-			if err != nil {
+// fail - returns err, or collects it and returns nil if Parser collects parse errors
+func (o *Command) fail(err error) error {
+	root := o.root()
+	if !root.collectErrors {
+		return err
+	}
+	root.parseErrors = append(root.parseErrors, err)
+	return nil
+}
+
+// invalid - reports error of argument oarg given token of args at position j, or negative j if there is no such token.
+// Unless Parser collects parse errors, the error is returned as it is and its position is recorded,
+// so that Parser.ErrorIndex can tell it. Collected errors are wrapped into InvalidArgumentError.
+func (o *Command) invalid(oarg *arg, err error, args *[]string, j int) error {
+	root := o.root()
+	if root.invalidArgs != nil {
+		root.invalidArgs[oarg] = true
+	}
+	index := -1
+	if j >= 0 {
+		index = o.argvIndex(args, j)
+	}
+	if !root.collectErrors {
+		root.lastErr, root.lastErrIndex = err, index
+		return err
+	}
+	return o.fail(InvalidArgumentError{Arg: oarg, Err: err, Index: index})
+}
+
+// argvIndex - returns position in the slice passed to Parser.Parse of token of args at position j.
+// Command names are removed from the beginning of args, while the rest of the slice stays in place
+func (o *Command) argvIndex(args *[]string, j int) int {
+	return len(o.root().argv) - len(*args) + j
+}
+
+// consume - marks n tokens of args starting at position j as consumed, so that they are neither parsed again
+// nor reported as unknown arguments
+func (o *Command) consume(args *[]string, j, n int) {
+	root := o.root()
+	for k := j; k < j+n && k < len(*args); k++ {
+		root.consumed[o.argvIndex(args, k)] = true
+	}
+}
+
+// isConsumed - tells whether token of args at position j was consumed
+func (o *Command) isConsumed(args *[]string, j int) bool {
+	return o.root().consumed[o.argvIndex(args, j)]
+}
+
+// checkArguments - checks that required arguments of this Command were provided
+// and sets default values of arguments that were not.
+// Arguments that were provided with invalid values are already reported and are skipped
+func (o *Command) checkArguments(args *[]string) error {
+	root := o.root()
+	for _, oarg := range o.args {
+		if oarg.parsed || oarg.opts == nil || root.invalidArgs[oarg] {
+			continue
+		}
+		if oarg.opts.Required {
+			if err := o.fail(MissingArgumentError{Arg: oarg}); err != nil {
 				return err
 			}
+			continue
+		}
+		if oarg.opts.Default != nil {
+			if err := oarg.setDefault(); err != nil {
+				if err := o.invalid(oarg, err, args, -1); err != nil {
+					return err
+				}
+			}
 		}
 	}
 	return nil
@@ -159,15 +237,43 @@ func (o *Command) parse(args *[]string) error {
 	o.happened = true
 
 	// Reduce arguments by removing Command name
+	o.consume(args, 0, 1)
 	*args = (*args)[1:]
 
-	// Parse subcommands if any
+	// Built-in commands (such as help) act as soon as they are selected
+	if o.action != nil {
+		if err := o.action(o, args); err != nil {
+			return err
+		}
+	}
+
+	// Pass-through commands capture all remaining tokens instead of parsing them
+	if o.passThrough != nil {
+		*o.passThrough = append(make([]string, 0, len(*args)), *args...)
+		o.consume(args, 0, len(*args))
+		if err := o.checkArguments(args); err != nil {
+			return err
+		}
+		o.parsed = true
+		return nil
+	}
+
+	// Parse subcommands if any, errors collected by Parser do not stop parsing
 	if err := o.parseSubCommands(args); err != nil {
-		return err
+		if err := o.fail(err); err != nil {
+			return err
+		}
+	}
+
+	// Arguments of the whole chain of commands are parsed by the last selected command
+	if !o.subCommandHappened() {
+		if err := o.parseTokens(args); err != nil {
+			return err
+		}
 	}
 
-	// Parse arguments if any
-	if err := o.parseArguments(args); err != nil {
+	// Check arguments of this command once all of them are parsed
+	if err := o.checkArguments(args); err != nil {
 		return err
 	}
 
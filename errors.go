@@ -1,5 +1,11 @@
 package argparse
 
+import (
+	"errors"
+	"fmt"
+	"strings"
+)
+
 type subCommandError struct {
 	error
 	cmd *Command
@@ -12,3 +18,297 @@ func (e subCommandError) Error() string {
 func newSubCommandError(cmd *Command) error {
 	return subCommandError{cmd: cmd}
 }
+
+type unknownCommandError struct {
+	cmd         *Command
+	name        string
+	suggestions []string
+}
+
+func (e unknownCommandError) Error() string {
+	msg := fmt.Sprintf("unknown command %s for %s", e.name, e.cmd.name)
+	if len(e.suggestions) > 0 {
+		msg += ". Did you mean " + strings.Join(e.suggestions, ", ") + "?"
+	}
+	return msg
+}
+
+func newUnknownCommandError(cmd *Command, name string) error {
+	suggestions := make([]string, 0)
+	for _, c := range cmd.commands {
+		if c.isHidden() {
+			continue
+		}
+		if strings.HasPrefix(c.name, name) || editDistance(c.name, name) <= 2 {
+			suggestions = append(suggestions, c.name)
+		}
+	}
+	return unknownCommandError{cmd: cmd, name: name, suggestions: suggestions}
+}
+
+// MissingArgumentError reports required argument that was not provided
+type MissingArgumentError struct {
+	Arg Arg
+}
+
+func (e MissingArgumentError) Error() string {
+	name := argName(e.Arg)
+	if name == "" {
+		return "required argument is missing"
+	}
+	return fmt.Sprintf("[%s] is required", name)
+}
+
+// InvalidArgumentError reports argument that was provided with invalid or missing values,
+// or whose default value could not be set, when Parser collects parse errors (see Parser.CollectErrors).
+// Otherwise Err, the error describing the problem, is returned by Parser.Parse as it is.
+// Index is the position in the slice passed to Parser.Parse of the invalid value (or of the argument itself
+// when values are missing), or -1 for default value.
+type InvalidArgumentError struct {
+	Arg   Arg
+	Err   error
+	Index int
+}
+
+func (e InvalidArgumentError) Error() string {
+	if e.Err != nil {
+		return e.Err.Error()
+	}
+	if name := argName(e.Arg); name != "" {
+		return fmt.Sprintf("[%s] is invalid", name)
+	}
+	return "invalid argument"
+}
+
+func (e InvalidArgumentError) Unwrap() error {
+	return e.Err
+}
+
+// argName - returns name of argument a the same way as arg.name does, for any implementation of Arg,
+// or empty string if a is nil or has no names
+func argName(a Arg) string {
+	if a == nil {
+		return ""
+	}
+	if p, ok := a.(*arg); ok && p == nil {
+		return ""
+	}
+	sname, lname := a.GetSname(), a.GetLname()
+	switch {
+	case sname == "" && lname == "":
+		return ""
+	case lname == "":
+		return "-" + sname
+	case sname == "":
+		return "--" + lname
+	}
+	return "-" + sname + "|--" + lname
+}
+
+// UnknownArgumentError reports command line token that does not match any argument or command.
+// Index is the position of the token in the slice passed to Parser.Parse.
+type UnknownArgumentError struct {
+	Token string
+	Index int
+}
+
+func (e UnknownArgumentError) Error() string {
+	return "unknown argument " + e.Token
+}
+
+type unknownArgumentsError struct {
+	tokens  []string
+	indexes []int
+}
+
+func (e unknownArgumentsError) Error() string {
+	return "unknown arguments " + strings.Join(e.tokens, " ")
+}
+
+// ParseErrors is returned by Parser.Parse when Parser collects parse errors (see Parser.CollectErrors)
+// and lists all of them in the order they were found
+type ParseErrors []error
+
+func (e ParseErrors) Error() string {
+	msg := fmt.Sprintf("%d errors occurred:", len(e))
+	if len(e) == 1 {
+		msg = "1 error occurred:"
+	}
+	for _, err := range e {
+		msg += "\n  * " + err.Error()
+	}
+	return msg
+}
+
+// Unwrap returns the individual errors, so that errors.Is and errors.As can match any of them
+// (with Go 1.20 and newer)
+func (e ParseErrors) Unwrap() []error {
+	return e
+}
+
+// Is reports whether any of the errors matches target, so that errors.Is works with older Go versions
+func (e ParseErrors) Is(target error) bool {
+	for _, err := range e {
+		if errors.Is(err, target) {
+			return true
+		}
+	}
+	return false
+}
+
+// As finds the first of the errors that matches target, so that errors.As works with older Go versions
+func (e ParseErrors) As(target interface{}) bool {
+	for _, err := range e {
+		if errors.As(err, target) {
+			return true
+		}
+	}
+	return false
+}
+
+// DefinitionErrors is returned by Parser.Validate and Parser.Parse when Parser defers definition errors
+// (see Parser.DeferErrors) and lists all of them in the order arguments were defined
+type DefinitionErrors []error
+
+func (e DefinitionErrors) Error() string {
+	msgs := make([]string, 0, len(e))
+	for _, err := range e {
+		msgs = append(msgs, err.Error())
+	}
+	return strings.Join(msgs, "\n")
+}
+
+// Unwrap returns the individual errors, so that errors.Is and errors.As can match any of them
+// (with Go 1.20 and newer)
+func (e DefinitionErrors) Unwrap() []error {
+	return e
+}
+
+// Is reports whether any of the errors matches target, so that errors.Is works with older Go versions
+func (e DefinitionErrors) Is(target error) bool {
+	return ParseErrors(e).Is(target)
+}
+
+// As finds the first of the errors that matches target, so that errors.As works with older Go versions
+func (e DefinitionErrors) As(target interface{}) bool {
+	return ParseErrors(e).As(target)
+}
+
+// errorIndexes - returns positions of command line tokens the error returned by Parse of this Parser is about
+// Err may be wrapped by the caller.
+func (o *Command) errorIndexes(err error) []int {
+	var parseErrors ParseErrors
+	var invalid InvalidArgumentError
+	var unknown UnknownArgumentError
+	var unknowns unknownArgumentsError
+	switch {
+	case errors.As(err, &parseErrors):
+		// Each of collected errors has its own position
+		return nil
+	case o.lastErr != nil && errors.Is(err, o.lastErr):
+		// Errors of arguments are returned as they are unless parse errors are collected, so their position is recorded
+		if o.lastErrIndex >= 0 {
+			return []int{o.lastErrIndex}
+		}
+	case errors.As(err, &invalid):
+		if invalid.Index >= 0 {
+			return []int{invalid.Index}
+		}
+	case errors.As(err, &unknown):
+		return []int{unknown.Index}
+	case errors.As(err, &unknowns):
+		return unknowns.indexes
+	}
+	return nil
+}
+
+// describeError - returns error message followed by the command line with carets under the tokens
+// the error is about, fitted into width. Each of ParseErrors is described separately.
+func (o *Command) describeError(err error, width int, theme *ColorTheme) string {
+	if errs, ok := err.(ParseErrors); ok {
+		msg := strings.SplitN(errs.Error(), "\n", 2)[0]
+		msg = theme.paint("error", msg)
+		for _, e := range errs {
+			msg += "\n  * " + strings.Replace(o.describeError(e, width-4, theme), "\n", "\n    ", -1)
+		}
+		return msg
+	}
+	msg := theme.paint("error", err.Error())
+	if snippet := errorSnippet(o.argv, o.errorIndexes(err), width-2, theme); snippet != "" {
+		msg += "\n" + snippet
+	}
+	return msg
+}
+
+// errorSnippet - returns command line args with carets under the tokens at indexes. Command line that does not
+// fit into width is shortened around the first of these tokens. Returns empty string if there are no such tokens.
+func errorSnippet(args []string, indexes []int, width int, theme *ColorTheme) string {
+	marked := make(map[int]bool)
+	first := -1
+	for _, i := range indexes {
+		if i < 0 || i >= len(args) {
+			continue
+		}
+		marked[i] = true
+		if first < 0 || i < first {
+			first = i
+		}
+	}
+	if first < 0 {
+		return ""
+	}
+
+	tokens := make([]string, len(args))
+	for i, a := range args {
+		tokens[i] = quoteToken(a)
+	}
+	// Tokens preceding the first marked one take at most half of the width
+	from, to := first, first+1
+	used := displayWidth(tokens[first])
+	for from > 0 && used+1+displayWidth(tokens[from-1]) <= width/2 {
+		from--
+		used += 1 + displayWidth(tokens[from])
+	}
+	for to < len(tokens) && used+1+displayWidth(tokens[to]) <= width {
+		used += 1 + displayWidth(tokens[to])
+		to++
+	}
+	// Width left after the last token is given to the preceding ones
+	for from > 0 && used+1+displayWidth(tokens[from-1]) <= width {
+		from--
+		used += 1 + displayWidth(tokens[from])
+	}
+
+	var line, carets strings.Builder
+	if from > 0 {
+		line.WriteString("... ")
+		carets.WriteString("    ")
+	}
+	for i := from; i < to; i++ {
+		if i > from {
+			line.WriteString(" ")
+			carets.WriteString(" ")
+		}
+		line.WriteString(tokens[i])
+		mark := " "
+		if marked[i] {
+			mark = "^"
+		}
+		carets.WriteString(strings.Repeat(mark, displayWidth(tokens[i])))
+	}
+	if to < len(tokens) {
+		line.WriteString(" ...")
+	}
+	return "  " + line.String() + "\n  " + theme.paint("error", strings.TrimRight(carets.String(), " "))
+}
+
+// quoteToken - quotes command line token the way POSIX shell would need it
+func quoteToken(s string) string {
+	if s == "" {
+		return "''"
+	}
+	if !strings.ContainsAny(s, " \t\n'\"\\$`*?;&|<>(){}[]#~") {
+		return s
+	}
+	return "'" + strings.Replace(s, "'", `'\''`, -1) + "'"
+}
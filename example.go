@@ -0,0 +1,121 @@
+package argparse
+
+import (
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"strings"
+)
+
+// ValidateExamples checks that every example added with Command.AddExample to the Parser returned by newParser,
+// or to any of its commands, can still be parsed. Each example is parsed by its own fresh Parser returned
+// by newParser, and has to select the command it was added to. Help invoked by examples neither prints nor exits,
+// and files of File and FileList arguments are neither opened nor created.
+// Returns an error listing all failed examples, or nil if all of them are valid.
+// Intended to be used in tests, so that examples do not go out of date when arguments change.
+func ValidateExamples(newParser func() *Parser) error {
+	failures := make([]string, 0)
+	newParser().walkExamples(nil, func(path []string, example Example) {
+		if err := validateExample(newParser(), path, example); err != nil {
+			failures = append(failures, fmt.Sprintf("example %q: %s", example.Command, err.Error()))
+		}
+	})
+	if len(failures) > 0 {
+		return errors.New(strings.Join(failures, "\n"))
+	}
+	return nil
+}
+
+// walkExamples - calls fn for each example of this Command and all its sub-commands with path of command names
+// from the top level Parser to the command example belongs to (not including Parser name)
+func (o *Command) walkExamples(path []string, fn func(path []string, example Example)) {
+	for _, example := range o.examples {
+		fn(path, example)
+	}
+	for _, cmd := range o.commands {
+		cmdPath := append(append(make([]string, 0, len(path)+1), path...), cmd.name)
+		cmd.walkExamples(cmdPath, fn)
+	}
+}
+
+// validateExample - parses example with provided parser and checks that command it belongs to was selected.
+// Parser is put into dry mode, so that files of File and FileList arguments are neither opened nor created
+func validateExample(p *Parser, path []string, example Example) error {
+	p.ExitOnHelp(false)
+	p.SetOutput(ioutil.Discard)
+	p.dry = true
+
+	args, err := splitCommandLine(example.Command)
+	if err != nil {
+		return err
+	}
+	if err := p.Parse(args); err != nil {
+		return err
+	}
+
+	cmd := &p.Command
+	for _, name := range path {
+		var next *Command
+		for _, c := range cmd.commands {
+			if c.name == name {
+				next = c
+			}
+		}
+		if next == nil {
+			return fmt.Errorf("command %s does not exist", strings.Join(path, " "))
+		}
+		cmd = next
+	}
+	if !cmd.Happened() {
+		return fmt.Errorf("command %s was not selected", strings.Join(path, " "))
+	}
+	return nil
+}
+
+// splitCommandLine - splits command line into arguments the same way POSIX shell does,
+// supporting single and double quotes and backslash escapes
+func splitCommandLine(line string) ([]string, error) {
+	args := make([]string, 0)
+	var current strings.Builder
+	inArg := false
+	var quote rune
+	escaped := false
+	for _, r := range line {
+		switch {
+		case escaped:
+			if quote == '"' && r != '"' && r != '\\' && r != '$' && r != '`' {
+				current.WriteRune('\\')
+			}
+			current.WriteRune(r)
+			escaped = false
+		case r == '\\' && quote != '\'':
+			escaped = true
+			inArg = true
+		case quote != 0:
+			if r == quote {
+				quote = 0
+			} else {
+				current.WriteRune(r)
+			}
+		case r == '\'' || r == '"':
+			quote = r
+			inArg = true
+		case r == ' ' || r == '\t' || r == '\n':
+			if inArg {
+				args = append(args, current.String())
+				current.Reset()
+				inArg = false
+			}
+		default:
+			current.WriteRune(r)
+			inArg = true
+		}
+	}
+	if quote != 0 || escaped {
+		return nil, fmt.Errorf("unterminated quote or escape in %q", line)
+	}
+	if inArg {
+		args = append(args, current.String())
+	}
+	return args, nil
+}
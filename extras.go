@@ -1,6 +1,124 @@
 package argparse
 
-import "strings"
+import (
+	"strings"
+	"unicode"
+	"unicode/utf8"
+)
+
+// wideRanges are the ranges of East Asian Wide and Fullwidth characters, including emoji presentation characters,
+// which occupy two cells of terminal
+var wideRanges = [][2]rune{
+	{0x1100, 0x115F}, {0x231A, 0x231B}, {0x2329, 0x232A}, {0x23E9, 0x23EC}, {0x23F0, 0x23F0}, {0x23F3, 0x23F3},
+	{0x25FD, 0x25FE}, {0x2614, 0x2615}, {0x2648, 0x2653}, {0x267F, 0x267F}, {0x2693, 0x2693}, {0x26A1, 0x26A1},
+	{0x26AA, 0x26AB}, {0x26BD, 0x26BE}, {0x26C4, 0x26C5}, {0x26CE, 0x26CE}, {0x26D4, 0x26D4}, {0x26EA, 0x26EA},
+	{0x26F2, 0x26F3}, {0x26F5, 0x26F5}, {0x26FA, 0x26FA}, {0x26FD, 0x26FD}, {0x2705, 0x2705}, {0x270A, 0x270B},
+	{0x2728, 0x2728}, {0x274C, 0x274C}, {0x274E, 0x274E}, {0x2753, 0x2755}, {0x2757, 0x2757}, {0x2795, 0x2797},
+	{0x27B0, 0x27B0}, {0x27BF, 0x27BF}, {0x2B1B, 0x2B1C}, {0x2B50, 0x2B50}, {0x2B55, 0x2B55}, {0x2E80, 0x303E},
+	{0x3041, 0x33FF}, {0x3400, 0x4DBF}, {0x4E00, 0x9FFF}, {0xA000, 0xA4CF}, {0xA960, 0xA97F}, {0xAC00, 0xD7A3},
+	{0xF900, 0xFAFF}, {0xFE10, 0xFE19}, {0xFE30, 0xFE6F}, {0xFF00, 0xFF60}, {0xFFE0, 0xFFE6}, {0x1F004, 0x1F004},
+	{0x1F0CF, 0x1F0CF}, {0x1F18E, 0x1F18E}, {0x1F191, 0x1F19A}, {0x1F200, 0x1F202}, {0x1F210, 0x1F23B},
+	{0x1F240, 0x1F248}, {0x1F250, 0x1F251}, {0x1F260, 0x1F265}, {0x1F300, 0x1F64F}, {0x1F680, 0x1F6FF},
+	{0x1F7E0, 0x1F7EB}, {0x1F90C, 0x1F9FF}, {0x1FA70, 0x1FAFF}, {0x20000, 0x2FFFD}, {0x30000, 0x3FFFD},
+}
+
+// runeWidth - returns number of terminal cells occupied by rune
+func runeWidth(r rune) int {
+	if r < 0x20 || unicode.In(r, unicode.Mn, unicode.Me, unicode.Cf) {
+		return 0
+	}
+	if r < 0x1100 {
+		return 1
+	}
+	for _, v := range wideRanges {
+		if r < v[0] {
+			break
+		}
+		if r <= v[1] {
+			return 2
+		}
+	}
+	return 1
+}
+
+// escapeLength - returns length in bytes of ANSI escape sequence at the beginning of s or 0 if there is none
+func escapeLength(s string) int {
+	if len(s) < 2 || s[0] != '\x1b' || s[1] != '[' {
+		return 0
+	}
+	for i := 2; i < len(s); i++ {
+		if s[i] >= 0x40 && s[i] <= 0x7e {
+			return i + 1
+		}
+	}
+	return len(s)
+}
+
+// displayWidth - returns number of terminal cells occupied by string, ANSI escape sequences are not counted
+func displayWidth(s string) int {
+	width := 0
+	for i := 0; i < len(s); {
+		if n := escapeLength(s[i:]); n > 0 {
+			i += n
+			continue
+		}
+		r, n := utf8.DecodeRuneInString(s[i:])
+		width += runeWidth(r)
+		i += n
+	}
+	return width
+}
+
+// splitAtWidth - splits string into head occupying at most width cells and the rest of it.
+// Head contains at least one rune, so that splitting always makes progress.
+func splitAtWidth(s string, width int) (string, string) {
+	used := 0
+	for i := 0; i < len(s); {
+		if n := escapeLength(s[i:]); n > 0 {
+			i += n
+			continue
+		}
+		r, n := utf8.DecodeRuneInString(s[i:])
+		if used+runeWidth(r) > width && i > 0 {
+			return s[:i], s[i:]
+		}
+		used += runeWidth(r)
+		i += n
+	}
+	return s, ""
+}
+
+// editDistance - returns Levenshtein distance between two strings counted in runes
+func editDistance(a, b string) int {
+	ra, rb := []rune(a), []rune(b)
+	prev := make([]int, len(rb)+1)
+	curr := make([]int, len(rb)+1)
+	for j := range prev {
+		prev[j] = j
+	}
+	for i := 1; i <= len(ra); i++ {
+		curr[0] = i
+		for j := 1; j <= len(rb); j++ {
+			cost := 1
+			if ra[i-1] == rb[j-1] {
+				cost = 0
+			}
+			curr[j] = min3(prev[j]+1, curr[j-1]+1, prev[j-1]+cost)
+		}
+		prev, curr = curr, prev
+	}
+	return prev[len(rb)]
+}
+
+func min3(a, b, c int) int {
+	if b < a {
+		a = b
+	}
+	if c < a {
+		a = c
+	}
+	return a
+}
 
 func getLastLine(input string) string {
 	slice := strings.Split(input, "\n")
@@ -9,8 +127,8 @@ func getLastLine(input string) string {
 
 func addToLastLine(base string, add string, width int, padding int, canSplit bool) string {
 	// If last line has less than 10% space left, do not try to fill in by splitting else just try to split
-	hasTen := (width - len(getLastLine(base))) > width/10
-	if len(getLastLine(base)+" "+add) >= width {
+	hasTen := (width - displayWidth(getLastLine(base))) > width/10
+	if displayWidth(getLastLine(base)+" "+add) >= width {
 		if hasTen && canSplit {
 			adds := strings.Split(add, " ")
 			for _, v := range adds {
@@ -19,6 +137,13 @@ func addToLastLine(base string, add string, width int, padding int, canSplit boo
 			return base
 		}
 		base = base + "\n" + strings.Repeat(" ", padding)
+		// Break words that are too long to fit even on a line of their own
+		limit := width - padding - 2
+		for !canSplit && limit > 0 && displayWidth(add) > limit {
+			var head string
+			head, add = splitAtWidth(add, limit)
+			base = base + " " + head + "\n" + strings.Repeat(" ", padding)
+		}
 	}
 	base = base + " " + add
 	return base
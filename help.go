@@ -0,0 +1,299 @@
+package argparse
+
+import (
+	"fmt"
+	"os"
+	"strconv"
+	"strings"
+	"text/template"
+)
+
+// DefaultHelpTemplate is the text/template used by Usage to render HelpModel when no custom template was set.
+// It can be used as a starting point for custom templates (see Command.SetHelpTemplate).
+//
+// Following functions are available to templates:
+//
+// wrap BASE ADD PADDING - appends ADD (string or []string) to the last line of BASE, wrapping it to the help width
+// (see Command.SetHelpWidth) and indenting continuation lines with PADDING spaces.
+//
+// pad N - returns a string of N spaces.
+//
+// indent N TEXT - indents every line of TEXT with N spaces.
+//
+// style KIND TEXT - highlights TEXT with the color of KIND ("heading", "command", "flag", "metavar", "required" or
+// "error") when colors are enabled (see Command.SetColor).
+const DefaultHelpTemplate = `{{.Message}}{{wrap (style "heading" "usage:") .UsageItems .UsagePadding}}
+
+{{wrap (pad .UsagePadding) .Description .UsagePadding}}
+
+{{if .Commands}}{{style "heading" "Commands:"}}
+
+{{range .Commands}}{{wrap .Heading .Description $.CommandPadding}}
+{{end}}
+{{end}}{{if .Arguments}}{{style "heading" "Arguments:"}}
+
+{{range .Arguments}}{{if .Help}}{{wrap .Heading .Help $.ArgumentPadding}}{{else}}{{.Heading}}{{end}}
+{{end}}
+{{end}}{{with .Examples}}{{style "heading" "Examples:"}}
+
+{{range .}}{{with .Description}}{{indent 2 .}}
+{{end}}{{indent 4 .Command}}
+{{end}}
+{{end}}{{with .Epilog}}{{.}}
+
+{{end}}`
+
+// HelpModel is a structured representation of the help message of a Command. It is what the help template
+// receives as its data.
+type HelpModel struct {
+	// Message is the text of error, string or fmt.Stringer passed to Usage followed by a new line. Empty if none.
+	Message string
+	// Chain is the list of command names from the top level Parser to the Command this help is for
+	Chain []string
+	// UsageItems are the items of the usage line: Chain, "<Command>" if Command has sub-commands and
+	// usage of every visible argument of this and all preceding commands
+	UsageItems []string
+	// Description is the description of the Command
+	Description string
+	// Commands are the visible sub-commands of the Command
+	Commands []HelpCommand
+	// Arguments are the visible arguments of this and all preceding commands
+	Arguments []HelpArgument
+	// Examples are the sample invocations of the Command (see Command.AddExample)
+	Examples []Example
+	// Epilog is the text displayed after all other sections (see Command.SetEpilog)
+	Epilog string
+	// UsagePadding is the indentation of usage line continuation and description
+	UsagePadding int
+	// CommandPadding is the indentation of command descriptions
+	CommandPadding int
+	// ArgumentPadding is the indentation of argument help messages
+	ArgumentPadding int
+}
+
+// HelpCommand is a single sub-command row of HelpModel
+type HelpCommand struct {
+	Name        string
+	Description string
+	// Heading is Name indented and padded up to the description column
+	Heading string
+}
+
+// HelpArgument is a single argument row of HelpModel
+type HelpArgument struct {
+	Sname    string
+	Lname    string
+	Required bool
+	// Usage is the argument as it is shown in usage line, e.g. [-s|--string "<value>"]
+	Usage string
+	// Help is the help message of the argument including its default value
+	Help string
+	// Heading is argument names indented and padded up to the help message column
+	Heading string
+}
+
+var defaultHelpTemplate = template.Must(template.New("help").Funcs(helpFuncs(80, nil)).Parse(DefaultHelpTemplate))
+
+// helpFuncs - returns functions available to help templates, wrapping lines at provided width
+// and highlighting text with provided color theme
+func helpFuncs(width int, theme *ColorTheme) template.FuncMap {
+	return template.FuncMap{
+		"wrap": func(base string, add interface{}, padding int) (string, error) {
+			switch v := add.(type) {
+			case string:
+				return addToLastLine(base, v, width, padding, true), nil
+			case []string:
+				for _, s := range v {
+					base = addToLastLine(base, s, width, padding, true)
+				}
+				return base, nil
+			}
+			return "", fmt.Errorf("wrap: unsupported type %T", add)
+		},
+		"pad": func(n int) string {
+			return strings.Repeat(" ", n)
+		},
+		"indent": func(n int, text string) string {
+			return strings.Repeat(" ", n) + strings.Replace(text, "\n", "\n"+strings.Repeat(" ", n), -1)
+		},
+		"style": theme.paint,
+	}
+}
+
+// SetHelpTemplate replaces the template used by Usage to render the help message of this Command
+// and all its sub-commands that do not have their own template. The template receives HelpModel as data.
+// Returns an error if the template cannot be parsed.
+func (o *Command) SetHelpTemplate(text string) error {
+	t, err := template.New("help").Funcs(helpFuncs(80, nil)).Parse(text)
+	if err != nil {
+		return err
+	}
+	o.helpTemplate = t
+	return nil
+}
+
+// SetHelpWidth sets the width to which the help message of this Command and all its sub-commands is wrapped.
+// If width is not set (or is not positive), it is taken from the COLUMNS environment variable when the output is
+// a terminal, defaulting to 80.
+func (o *Command) SetHelpWidth(width int) {
+	o.helpWidth = width
+}
+
+// getHelpWidth - returns help width of this Command, inherited from its parents or detected from environment
+func (o *Command) getHelpWidth() int {
+	for current := o; current != nil; current = current.parent {
+		if current.helpWidth > 0 {
+			return current.helpWidth
+		}
+	}
+	output := o.getOutput()
+	if output == nil {
+		output = os.Stdout
+	}
+	if isTerminal(output) {
+		if columns, err := strconv.Atoi(os.Getenv("COLUMNS")); err == nil && columns > 0 {
+			return columns
+		}
+	}
+	// Stay classy
+	return 80
+}
+
+// getHelpTemplate - returns template of this Command, inherited from its parents or the default one
+func (o *Command) getHelpTemplate() *template.Template {
+	for current := o; current != nil; current = current.parent {
+		if current.helpTemplate != nil {
+			return current.helpTemplate
+		}
+	}
+	return defaultHelpTemplate
+}
+
+// isHidden - tells whether argument must not be shown in the help message
+func (o *arg) isHidden() bool {
+	return o.opts != nil && (o.opts.Hidden || o.opts.Help == DisableDescription)
+}
+
+// longUsageName - returns long name of argument as shown in help message, e.g. --[no-]cache for negatable flag
+func (o *arg) longUsageName() string {
+	if o.isNegatable() {
+		return "--[no-]" + o.lname
+	}
+	return "--" + o.lname
+}
+
+// GetHelpModel returns structured help message of this Command, which is used by Usage to render its output.
+// Accepts an interface that can be error, string or fmt.Stringer that will be used as HelpModel.Message.
+func (o *Command) GetHelpModel(msg interface{}) *HelpModel {
+	return o.helpModel(msg, nil, false)
+}
+
+// helpModel - builds HelpModel highlighting command and argument names with provided color theme.
+// Hidden commands and arguments are included if all is true.
+func (o *Command) helpModel(msg interface{}, theme *ColorTheme, all bool) *HelpModel {
+	model := &HelpModel{
+		Description: o.GetDescription(),
+		Examples:    o.examples,
+		Epilog:      o.epilog,
+	}
+	model.Message = message2String(msg)
+	if err, ok := msg.(error); ok && model.Message != "" {
+		model.Message = o.root().describeError(err, o.getHelpWidth(), theme) + "\n"
+	}
+
+	// List of arguments from all preceding commands
+	arguments := make([]*arg, 0)
+	o.getPrecedingCommands(&model.Chain, &arguments)
+	for _, name := range model.Chain {
+		model.UsageItems = append(model.UsageItems, theme.paint("command", name))
+	}
+	model.UsagePadding = displayWidth("usage: " + model.Chain[0])
+
+	// If this Command has sub-commands we need their list
+	commands := o.getSubCommands(&model.UsageItems, all)
+	if len(o.commands) > 0 {
+		model.UsageItems[len(model.UsageItems)-1] = theme.paint("metavar", "<Command>")
+	}
+	for _, com := range commands {
+		if displayWidth("  "+com.name+"  ") > model.CommandPadding {
+			model.CommandPadding = displayWidth("  " + com.name + "  ")
+		}
+	}
+	for _, com := range commands {
+		heading := "  " + theme.paint("command", com.name)
+		model.Commands = append(model.Commands, HelpCommand{
+			Name:        com.name,
+			Description: com.GetDescription(),
+			Heading:     heading + strings.Repeat(" ", model.CommandPadding-displayWidth(heading)-1),
+		})
+	}
+
+	for _, argument := range arguments {
+		if argument.isHidden() && !all {
+			continue
+		}
+		model.UsageItems = append(model.UsageItems, argument.styledUsage(theme))
+		if displayWidth(argument.longUsageName())+7 > model.ArgumentPadding {
+			model.ArgumentPadding = displayWidth(argument.longUsageName()) + 7
+		}
+	}
+	if o.passThrough != nil {
+		model.UsageItems = append(model.UsageItems, theme.paint("metavar", "[<args> ...]"))
+	}
+	for _, argument := range arguments {
+		if argument.isHidden() && !all {
+			continue
+		}
+		kind := "flag"
+		if argument.opts != nil && argument.opts.Required {
+			kind = "required"
+		}
+		heading := "  "
+		if argument.sname != "" {
+			heading = heading + theme.paint(kind, "-"+argument.sname) + "  "
+		} else {
+			heading = heading + "    "
+		}
+		heading = heading + theme.paint(kind, argument.longUsageName())
+		row := HelpArgument{
+			Sname:   argument.sname,
+			Lname:   argument.lname,
+			Usage:   argument.usage(),
+			Heading: heading + strings.Repeat(" ", model.ArgumentPadding-displayWidth(heading)),
+		}
+		if argument.opts != nil {
+			row.Required = argument.opts.Required
+			if argument.opts.Help != "" && argument.opts.Help != DisableDescription {
+				row.Help = argument.getHelpMessage()
+			}
+		}
+		model.Arguments = append(model.Arguments, row)
+	}
+
+	return model
+}
+
+// renderHelp - executes help template of this Command over its HelpModel.
+// Hidden commands and arguments are included if all is true.
+func (o *Command) renderHelp(msg interface{}, all bool) string {
+	theme := o.getColorTheme()
+	t, err := o.getHelpTemplate().Clone()
+	if err != nil {
+		return err.Error()
+	}
+	var result strings.Builder
+	if err := t.Funcs(helpFuncs(o.getHelpWidth(), theme)).Execute(&result, o.helpModel(msg, theme, all)); err != nil {
+		return result.String() + err.Error()
+	}
+	return result.String()
+}
+
+// usageAll - returns help message of the selected command including hidden commands and arguments
+func (o *Command) usageAll() string {
+	for _, cmd := range o.commands {
+		if cmd.Happened() {
+			return cmd.usageAll()
+		}
+	}
+	return o.renderHelp(nil, true)
+}
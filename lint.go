@@ -0,0 +1,96 @@
+package argparse
+
+import (
+	"fmt"
+	"strings"
+)
+
+// Lint checks definition of the whole Parser tree for mistakes that are not detected when arguments are added,
+// and returns list of found problems. Intended to be used in tests.
+// Following problems are reported:
+//
+// - argument that reuses short or long name of an argument of any preceding command, which it shadows
+//
+// - default value which type does not match the type of argument
+//
+// - required argument with default value, which is never used
+//
+// - default value of Selector which is not among allowed values
+//
+// - Selector with no allowed values
+//
+// - Negatable option of an argument other than Flag, or default value of nullable argument, which are ignored
+//
+// - command with no description
+//
+// - pass-through command with sub-commands
+func (o *Parser) Lint() []error {
+	problems := make([]error, 0)
+	o.Command.lint(&problems)
+	return problems
+}
+
+// lint - checks this Command and all its sub-commands, appending found problems
+func (o *Command) lint(problems *[]error) {
+	path := strings.Join(o.GetPath(), " ")
+	report := func(format string, a ...interface{}) {
+		*problems = append(*problems, fmt.Errorf("command %s: %s", path, fmt.Sprintf(format, a...)))
+	}
+
+	if o.description == "" && o.builtin == "" {
+		report("no description")
+	}
+	if o.passThrough != nil && len(o.commands) > 0 {
+		report("pass-through command has sub-commands, which are never selected")
+	}
+
+	for _, a := range o.args {
+		for current := o.parent; current != nil; current = current.parent {
+			for _, v := range current.args {
+				if a.sname != "" && a.sname == v.sname {
+					report("argument [%s] shadows short name of argument [%s] of command %s", a.name(), v.name(), current.name)
+				}
+				if a.lname == v.lname {
+					report("argument [%s] shadows long name of argument [%s] of command %s", a.name(), v.name(), current.name)
+				}
+			}
+		}
+
+		if a.selector != nil && len(*a.selector) == 0 {
+			report("argument [%s] has no allowed values", a.name())
+		}
+		if a.opts == nil {
+			continue
+		}
+		if a.opts.Negatable && !a.isNegatable() {
+			report("argument [%s] is not a flag and cannot be negatable", a.name())
+		}
+		if a.opts.Default == nil {
+			continue
+		}
+		if err := a.checkDefaultType(); err != nil {
+			report("argument [%s] %s", a.name(), err.Error())
+		} else if a.selector != nil && !containsString(*a.selector, a.opts.Default.(string)) {
+			report("argument [%s] default value %s is not among allowed values %v", a.name(), a.opts.Default, *a.selector)
+		}
+		if a.opts.Required {
+			report("argument [%s] is required and has default value, which is never used", a.name())
+		}
+		if a.nullable != nil {
+			report("argument [%s] is nullable and its default value is ignored", a.name())
+		}
+	}
+
+	for _, c := range o.commands {
+		c.lint(problems)
+	}
+}
+
+func containsString(list []string, s string) bool {
+	for _, v := range list {
+		if v == s {
+			return true
+		}
+	}
+	return false
+}
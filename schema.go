@@ -0,0 +1,119 @@
+package argparse
+
+import (
+	"encoding/json"
+	"os"
+)
+
+// SchemaVersion is the version of the format of Schema. It is increased whenever the format changes
+// in a way that is not backward compatible.
+const SchemaVersion = 1
+
+// Schema is a machine-readable description of the whole Parser tree, see Parser.GetSchema
+type Schema struct {
+	Version int           `json:"version"`
+	Parser  CommandSchema `json:"parser"`
+}
+
+// CommandSchema describes Parser or Command
+type CommandSchema struct {
+	Name        string `json:"name"`
+	Description string `json:"description,omitempty"`
+	Hidden      bool   `json:"hidden,omitempty"`
+	// Builtin is "help" or "version" for commands added with Parser.EnableHelpCommand
+	// and Parser.EnableVersionCommand
+	Builtin   string           `json:"builtin,omitempty"`
+	Version   string           `json:"version,omitempty"`
+	Epilog    string           `json:"epilog,omitempty"`
+	Examples  []Example        `json:"examples,omitempty"`
+	Arguments []ArgumentSchema `json:"arguments,omitempty"`
+	Commands  []CommandSchema  `json:"commands,omitempty"`
+	// Ordering is set only if Command has its own ordering, see Command.SetOrdering
+	Ordering Ordering `json:"ordering,omitempty"`
+	// PassThrough tells whether Command captures all tokens following its name, see Command.EnablePassThrough
+	PassThrough bool `json:"passThrough,omitempty"`
+}
+
+// ArgumentSchema describes argument of a Command
+type ArgumentSchema struct {
+	Short string `json:"short,omitempty"`
+	Long  string `json:"long"`
+	// Type is the type of argument as returned by Arg.GetType
+	Type      string      `json:"type"`
+	Arity     int         `json:"arity"`
+	Required  bool        `json:"required,omitempty"`
+	Default   interface{} `json:"default,omitempty"`
+	Choices   []string    `json:"choices,omitempty"`
+	Help      string      `json:"help,omitempty"`
+	Hidden    bool        `json:"hidden,omitempty"`
+	Negatable bool        `json:"negatable,omitempty"`
+	Nullable  bool        `json:"nullable,omitempty"`
+	// DuplicateKeys is the policy of map arguments, see Options.DuplicateKeys
+	DuplicateKeys DuplicateKeyPolicy `json:"duplicateKeys,omitempty"`
+	// FileFlag and FilePerm are the flags and permissions files are opened with, for file and fileList types
+	FileFlag int    `json:"fileFlag,omitempty"`
+	FilePerm uint32 `json:"filePerm,omitempty"`
+}
+
+// GetSchema returns description of the whole Parser tree: commands, arguments and their options
+func (o *Parser) GetSchema() *Schema {
+	return &Schema{
+		Version: SchemaVersion,
+		Parser:  o.Command.getSchema(),
+	}
+}
+
+// ExportSchema returns description of the whole Parser tree (see Parser.GetSchema) as JSON document
+func (o *Parser) ExportSchema() ([]byte, error) {
+	return json.MarshalIndent(o.GetSchema(), "", "  ")
+}
+
+// getSchema - describes this Command and all its sub-commands
+func (o *Command) getSchema() CommandSchema {
+	s := CommandSchema{
+		Name:        o.name,
+		Description: o.GetDescription(),
+		Hidden:      o.isHidden(),
+		Builtin:     o.builtin,
+		Version:     o.version,
+		Ordering:    o.ordering,
+		PassThrough: o.passThrough != nil,
+		Epilog:      o.epilog,
+		Examples:    o.examples,
+	}
+	for _, a := range o.args {
+		s.Arguments = append(s.Arguments, a.getSchema())
+	}
+	for _, c := range o.commands {
+		s.Commands = append(s.Commands, c.getSchema())
+	}
+	return s
+}
+
+// getSchema - describes argument
+func (o *arg) getSchema() ArgumentSchema {
+	s := ArgumentSchema{
+		Short:    o.sname,
+		Long:     o.lname,
+		Type:     o.GetType(),
+		Arity:    o.GetArity(),
+		Choices:  o.GetSelector(),
+		Hidden:   o.isHidden(),
+		Nullable: o.nullable != nil,
+	}
+	if o.opts != nil {
+		s.Required = o.opts.Required
+		s.Default = o.opts.Default
+		s.Negatable = o.opts.Negatable
+		s.DuplicateKeys = o.opts.DuplicateKeys
+		if o.opts.Help != DisableDescription {
+			s.Help = o.opts.Help
+		}
+	}
+	switch o.result.(type) {
+	case *os.File, *[]os.File:
+		s.FileFlag = o.fileFlag
+		s.FilePerm = uint32(o.filePerm)
+	}
+	return s
+}
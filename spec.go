@@ -0,0 +1,285 @@
+package argparse
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"reflect"
+	"strings"
+)
+
+// NewParserFromSpec creates new Parser from JSON document in the format produced by Parser.ExportSchema,
+// so that a definition can be exported and built back. Handlers are bound to commands by path of command names
+// separated by spaces, not including the program name (empty path is the Parser itself), see Command.SetHandler.
+// Values of arguments are available through Command.GetArg and Arg.GetValue.
+// Returns an error if document is not valid or a handler path does not match any command.
+func NewParserFromSpec(spec []byte, handlers map[string]func(c *Command) error) (*Parser, error) {
+	var schema Schema
+	if err := json.Unmarshal(spec, &schema); err != nil {
+		return nil, err
+	}
+	return NewParserFromSchema(&schema, handlers)
+}
+
+// NewParserFromSchema creates new Parser from Schema, see NewParserFromSpec
+func NewParserFromSchema(schema *Schema, handlers map[string]func(c *Command) error) (*Parser, error) {
+	if schema.Version != SchemaVersion {
+		return nil, fmt.Errorf("unsupported schema version %d", schema.Version)
+	}
+
+	p := NewParser(schema.Parser.Name, schema.Parser.Description)
+	p.DisableHelp()
+	p.DeferErrors(true)
+	if err := p.buildFromSchema(p, schema.Parser); err != nil {
+		return nil, err
+	}
+	p.DeferErrors(false)
+
+	for path, handler := range handlers {
+		cmd := &p.Command
+		for _, name := range strings.Fields(path) {
+			var next *Command
+			for _, c := range cmd.commands {
+				if c.name == name {
+					next = c
+				}
+			}
+			if next == nil {
+				return nil, fmt.Errorf("handler path %q does not match any command", path)
+			}
+			cmd = next
+		}
+		cmd.SetHandler(handler)
+	}
+	return p, nil
+}
+
+// buildFromSchema - adds arguments and sub-commands described by schema to this Command
+func (o *Command) buildFromSchema(p *Parser, s CommandSchema) error {
+	o.Hidden = s.Hidden
+	o.ordering = s.Ordering
+	if s.PassThrough {
+		o.EnablePassThrough()
+	}
+	o.epilog = s.Epilog
+	o.examples = s.Examples
+	if s.Version != "" {
+		if o != &p.Command {
+			return fmt.Errorf("command %s: only parser can have version", o.name)
+		}
+		p.version = s.Version
+	}
+	for _, a := range s.Arguments {
+		if err := o.addArgFromSchema(p, a); err != nil {
+			return fmt.Errorf("command %s: argument %s: %s", o.name, a.Long, err.Error())
+		}
+	}
+	for _, cs := range s.Commands {
+		var c *Command
+		switch cs.Builtin {
+		case "":
+			c = o.NewCommand(cs.Name, cs.Description)
+		case "help", "version":
+			if o != &p.Command {
+				return fmt.Errorf("command %s: builtin %s command must belong to parser", o.name, cs.Builtin)
+			}
+			if cs.Builtin == "help" {
+				c = p.EnableHelpCommand()
+			} else {
+				c = p.EnableVersionCommand()
+			}
+			c.name = cs.Name
+			c.description = cs.Description
+		default:
+			return fmt.Errorf("command %s: unknown builtin %s", cs.Name, cs.Builtin)
+		}
+		if err := c.buildFromSchema(p, cs); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// addArgFromSchema - adds argument described by schema to this Command
+func (o *Command) addArgFromSchema(p *Parser, s ArgumentSchema) error {
+	// Parser is in deferred errors mode, so definition error of this argument is the last collected one
+	defErrors := len(p.defErrors)
+	if err := o.addArgOfType(p, s); err != nil {
+		return err
+	}
+	if len(p.defErrors) > defErrors {
+		err := p.defErrors[defErrors]
+		p.defErrors = p.defErrors[:defErrors]
+		return err
+	}
+	return nil
+}
+
+// addArgOfType - adds argument described by schema to this Command using constructor of its type
+func (o *Command) addArgOfType(p *Parser, s ArgumentSchema) error {
+	opts := &Options{
+		Required:      s.Required,
+		Help:          s.Help,
+		Hidden:        s.Hidden,
+		Negatable:     s.Negatable,
+		DuplicateKeys: s.DuplicateKeys,
+	}
+	if s.Default != nil {
+		var err error
+		if opts.Default, err = convertDefault(s.Type, s.Default); err != nil {
+			return err
+		}
+	}
+	if s.Nullable {
+		switch s.Type {
+		case "flag", "string", "int", "float":
+		default:
+			return fmt.Errorf("type %s cannot be nullable", s.Type)
+		}
+	}
+
+	switch s.Type {
+	case "help":
+		o.help(s.Short, s.Long)
+	case "helpAll":
+		if o != &p.Command {
+			return fmt.Errorf("only parser can have help-all argument")
+		}
+		p.EnableHelpAll()
+	case "version":
+		if o != &p.Command {
+			return fmt.Errorf("only parser can have version argument")
+		}
+		version := p.version
+		p.version = ""
+		p.SetVersion(version)
+	case "flag":
+		if s.Nullable {
+			o.NullableFlag(s.Short, s.Long, opts)
+		} else {
+			o.Flag(s.Short, s.Long, opts)
+		}
+	case "counter":
+		o.FlagCounter(s.Short, s.Long, opts)
+	case "string":
+		if s.Nullable {
+			o.NullableString(s.Short, s.Long, opts)
+		} else {
+			o.String(s.Short, s.Long, opts)
+		}
+	case "selector":
+		o.Selector(s.Short, s.Long, s.Choices, opts)
+	case "int":
+		if s.Nullable {
+			o.NullableInt(s.Short, s.Long, opts)
+		} else {
+			o.Int(s.Short, s.Long, opts)
+		}
+	case "float":
+		if s.Nullable {
+			o.NullableFloat(s.Short, s.Long, opts)
+		} else {
+			o.Float(s.Short, s.Long, opts)
+		}
+	case "file":
+		o.File(s.Short, s.Long, s.FileFlag, os.FileMode(s.FilePerm), opts)
+	case "stringList":
+		o.StringList(s.Short, s.Long, opts)
+	case "intList":
+		o.IntList(s.Short, s.Long, opts)
+	case "floatList":
+		o.FloatList(s.Short, s.Long, opts)
+	case "fileList":
+		o.FileList(s.Short, s.Long, s.FileFlag, os.FileMode(s.FilePerm), opts)
+	case "stringMap":
+		o.StringMap(s.Short, s.Long, opts)
+	case "intMap":
+		o.IntMap(s.Short, s.Long, opts)
+	case "floatMap":
+		o.FloatMap(s.Short, s.Long, opts)
+	default:
+		return fmt.Errorf("unknown type %s", s.Type)
+	}
+	return nil
+}
+
+// convertDefault - converts default value decoded from JSON to the type expected by argument of provided type
+func convertDefault(typ string, value interface{}) (interface{}, error) {
+	switch typ {
+	case "flag":
+		if v, ok := value.(bool); ok {
+			return v, nil
+		}
+	case "string", "selector", "file":
+		if v, ok := value.(string); ok {
+			return v, nil
+		}
+	case "int", "counter", "float":
+		if v, ok := value.(float64); ok {
+			if typ == "float" {
+				return v, nil
+			}
+			if v == float64(int(v)) {
+				return int(v), nil
+			}
+		}
+	case "stringList", "fileList", "intList", "floatList":
+		values, ok := value.([]interface{})
+		if !ok {
+			break
+		}
+		elemType := map[string]string{"stringList": "string", "fileList": "string", "intList": "int", "floatList": "float"}[typ]
+		converted := make([]interface{}, 0, len(values))
+		for _, v := range values {
+			c, err := convertDefault(elemType, v)
+			if err != nil {
+				return nil, err
+			}
+			converted = append(converted, c)
+		}
+		switch elemType {
+		case "string":
+			result := make([]string, 0, len(converted))
+			for _, v := range converted {
+				result = append(result, v.(string))
+			}
+			return result, nil
+		case "int":
+			result := make([]int, 0, len(converted))
+			for _, v := range converted {
+				result = append(result, v.(int))
+			}
+			return result, nil
+		default:
+			result := make([]float64, 0, len(converted))
+			for _, v := range converted {
+				result = append(result, v.(float64))
+			}
+			return result, nil
+		}
+	case "stringMap", "intMap", "floatMap":
+		values, ok := value.(map[string]interface{})
+		if !ok {
+			break
+		}
+		elemType := strings.TrimSuffix(typ, "Map")
+		var result reflect.Value
+		switch elemType {
+		case "string":
+			result = reflect.ValueOf(make(map[string]string))
+		case "int":
+			result = reflect.ValueOf(make(map[string]int))
+		default:
+			result = reflect.ValueOf(make(map[string]float64))
+		}
+		for k, v := range values {
+			c, err := convertDefault(elemType, v)
+			if err != nil {
+				return nil, err
+			}
+			result.SetMapIndex(reflect.ValueOf(k), reflect.ValueOf(c))
+		}
+		return result.Interface(), nil
+	}
+	return nil, fmt.Errorf("default value %v cannot be used for type %s", value, typ)
+}
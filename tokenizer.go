@@ -0,0 +1,258 @@
+package argparse
+
+import (
+	"fmt"
+	"strings"
+)
+
+// Ordering defines where arguments can appear among command line tokens
+type Ordering int
+
+const (
+	// Interspersed ordering recognizes arguments anywhere after command names, mixed with other tokens.
+	// It is used unless another ordering is set.
+	Interspersed Ordering = iota + 1
+	// StopAtNonOption ordering stops recognizing arguments at the first token that is neither an argument
+	// nor its value, as getopt does. This token and all following ones are left unparsed.
+	// Token "--" also stops recognizing arguments, but is consumed itself.
+	StopAtNonOption
+)
+
+var orderingNames = map[Ordering]string{
+	Interspersed:    "interspersed",
+	StopAtNonOption: "stopAtNonOption",
+}
+
+// MarshalText encodes ordering as its name ("interspersed" or "stopAtNonOption"), as it appears in JSON schema
+func (o Ordering) MarshalText() ([]byte, error) {
+	if name, ok := orderingNames[o]; ok {
+		return []byte(name), nil
+	}
+	return nil, fmt.Errorf("unknown ordering %d", int(o))
+}
+
+// UnmarshalText decodes ordering from its name, see Ordering.MarshalText
+func (o *Ordering) UnmarshalText(text []byte) error {
+	for ordering, name := range orderingNames {
+		if name == string(text) {
+			*o = ordering
+			return nil
+		}
+	}
+	return fmt.Errorf("unknown ordering %q", text)
+}
+
+// SetOrdering sets where arguments can appear among command line tokens when this Command is selected,
+// for this Command and all its sub-commands that do not have their own ordering. Ordering of the selected
+// command applies to arguments of its parents too.
+func (o *Command) SetOrdering(ordering Ordering) {
+	o.ordering = ordering
+}
+
+// getOrdering - returns ordering of this Command, inherited from its parents
+func (o *Command) getOrdering() Ordering {
+	for current := o; current != nil; current = current.parent {
+		if current.ordering != 0 {
+			return current.ordering
+		}
+	}
+	return Interspersed
+}
+
+// argTable - arguments of a chain of commands by their names, which command line tokens are looked up in
+type argTable struct {
+	short map[string]*arg
+	long  map[string]*arg
+}
+
+// newArgTable - returns table of arguments of cmd and all its parents.
+// Arguments of sub-commands take precedence over arguments of their parents with the same names.
+func newArgTable(cmd *Command) *argTable {
+	t := &argTable{short: make(map[string]*arg), long: make(map[string]*arg)}
+	for current := cmd; current != nil; current = current.parent {
+		for _, a := range current.args {
+			if _, ok := t.long[a.lname]; !ok {
+				t.long[a.lname] = a
+			}
+			if _, ok := t.short[a.sname]; !ok && a.sname != "" {
+				t.short[a.sname] = a
+			}
+		}
+	}
+	return t
+}
+
+// lookupLong - returns argument with long name, and whether name is its negation, as in no-cache
+func (t *argTable) lookupLong(name string) (*arg, bool) {
+	if a, ok := t.long[name]; ok {
+		return a, false
+	}
+	if strings.HasPrefix(name, "no-") {
+		if a, ok := t.long[name[3:]]; ok && a.isNegatable() {
+			return a, true
+		}
+	}
+	return nil, false
+}
+
+// parseTokens - parses args in a single pass from left to right, looking tokens up among arguments of this Command
+// and all its parents. Tokens that are not arguments are left to be reported as unknown arguments,
+// with StopAtNonOption ordering parsing stops at the first of them.
+func (o *Command) parseTokens(args *[]string) error {
+	table := newArgTable(o)
+	stop := o.getOrdering() == StopAtNonOption
+	for j := 0; j < len(*args); j++ {
+		if o.isConsumed(args, j) {
+			continue
+		}
+		token := (*args)[j]
+		var err error
+		switch {
+		case len(token) > 2 && strings.HasPrefix(token, "--") && token[2] != '-':
+			j, err = o.parseLongToken(table, args, j)
+		case len(token) > 1 && token[0] == '-' && token[1] != '-':
+			j, err = o.parseShortToken(table, args, j)
+		case stop && token == "--":
+			o.consume(args, j, 1)
+			return nil
+		case stop:
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// parseLongToken - parses token at position j, which is a long name of argument, with its values.
+// Returns position of the last consumed token.
+func (o *Command) parseLongToken(table *argTable, args *[]string, j int) (int, error) {
+	token := (*args)[j][2:]
+	// Long name itself may contain equals char, so value follows the first one that ends a known name
+	var a *arg
+	var negated, eq bool
+	var value string
+	for i := 0; i < len(token) && a == nil; i++ {
+		if token[i] != '=' {
+			continue
+		}
+		if a, negated = table.lookupLong(token[:i]); a != nil {
+			value, eq = token[i+1:], true
+		}
+	}
+	if a == nil {
+		a, negated = table.lookupLong(token)
+	}
+	if a == nil {
+		return j, nil
+	}
+
+	a.negated = negated
+	if eq {
+		o.consume(args, j, 1)
+		if value == "" {
+			return j, o.invalid(a, fmt.Errorf("not enough arguments for %s", a.name()), args, j)
+		}
+		return j, o.parseArg(a, []string{value}, 1, args, j, j, value)
+	}
+	o.consume(args, j, a.size)
+	if len(*args) < j+a.size {
+		return j + a.size - 1, o.invalid(a, fmt.Errorf("not enough arguments for %s", a.name()), args, j)
+	}
+	return j + a.size - 1, o.parseArg(a, (*args)[j+1:j+a.size], 1, args, j, j+1, (*args)[j+a.size-1])
+}
+
+// parseShortToken - parses token at position j, which is a run of short names of arguments. The first argument
+// in the run that takes values ends it: the rest of the token is its value, as in -ofile, -o=file or -xvfout.tar,
+// or if there is nothing left its values are the following tokens. Value after equals char can also be given
+// to the last flag, as in -v=false. Names that are not known are left in the token to be reported
+// as unknown arguments. Returns position of the last consumed token.
+func (o *Command) parseShortToken(table *argTable, args *[]string, j int) (int, error) {
+	token := (*args)[j]
+	names := []rune(token[1:])
+
+	// Arguments in order of their first occurrence in the token, with number of occurrences
+	matched := make([]*arg, 0, len(names))
+	counts := make(map[*arg]int)
+	var unknown strings.Builder
+	// Argument that is given value, which is attached to the name if attached is true
+	var last *arg
+	var value string
+	attached := false
+	var prev *arg
+	for k := 0; k < len(names) && last == nil; k++ {
+		if names[k] == '=' {
+			if prev == nil {
+				unknown.WriteString(string(names[k:]))
+				break
+			}
+			last, value, attached = prev, string(names[k+1:]), true
+			break
+		}
+		a, ok := table.short[string(names[k])]
+		if !ok {
+			unknown.WriteRune(names[k])
+			prev = nil
+			continue
+		}
+		if counts[a] == 0 {
+			matched = append(matched, a)
+		}
+		counts[a]++
+		prev = a
+		if a.size > 1 {
+			last = a
+			if rest := string(names[k+1:]); rest != "" {
+				value, attached = strings.TrimPrefix(rest, "="), true
+			}
+		}
+	}
+	if len(matched) == 0 {
+		return j, nil
+	}
+
+	if unknown.Len() > 0 {
+		(*args)[j] = "-" + unknown.String()
+	} else {
+		o.consume(args, j, 1)
+	}
+
+	end := j
+	for _, a := range matched {
+		var err error
+		a.negated = false
+		switch {
+		case a != last:
+			err = o.parseArg(a, nil, counts[a], args, j, j, token)
+		case attached && counts[a] > 1:
+			err = o.invalid(a, fmt.Errorf("[%s] can only be present once when given a value", a.name()), args, j)
+		case attached && value == "":
+			err = o.invalid(a, fmt.Errorf("not enough arguments for %s", a.name()), args, j)
+		case attached:
+			err = o.parseArg(a, []string{value}, counts[a], args, j, j, value)
+		case len(*args) < j+a.size:
+			end = j + a.size - 1
+			o.consume(args, j+1, a.size-1)
+			err = o.invalid(a, fmt.Errorf("not enough arguments for %s", a.name()), args, j)
+		default:
+			end = j + a.size - 1
+			o.consume(args, j+1, a.size-1)
+			err = o.parseArg(a, (*args)[j+1:j+a.size], counts[a], args, j, j+1, (*args)[j+a.size-1])
+		}
+		if err != nil {
+			return end, err
+		}
+	}
+	return end, nil
+}
+
+// parseArg - parses values of argument a given token of args at position j, with values starting at position v
+// (which is j when value is attached to the name). Raw is the value as it appears on the command line.
+func (o *Command) parseArg(a *arg, values []string, count int, args *[]string, j, v int, raw string) error {
+	if err := a.parse(values, count); err != nil {
+		return o.invalid(a, err, args, v)
+	}
+	a.source = Source{Kind: SourceCommandLine, Index: o.argvIndex(args, j), Raw: raw}
+	return nil
+}
@@ -0,0 +1,92 @@
+package argparse
+
+import (
+	"runtime/debug"
+)
+
+type version struct{}
+
+// readBuildInfo is used to get build information of the program, overwritten while testing
+var readBuildInfo = debug.ReadBuildInfo
+
+// SetVersion sets version of the program and adds -V|--version argument, which prints it in the same way
+// help argument prints help message (see Command.SetOutput and Command.ExitOnHelp).
+// Can be called multiple times to change version.
+func (o *Parser) SetVersion(v string) {
+	if o.version == "" {
+		a := &arg{
+			result: &version{},
+			sname:  "V",
+			lname:  "version",
+			size:   1,
+			opts:   &Options{Help: "Print version information"},
+			unique: true,
+		}
+
+		o.addArgOrFail("version", a)
+	}
+	o.version = v
+}
+
+// SetVersionFromBuildInfo sets version of the program (see Parser.SetVersion) derived from build information
+// embedded into the binary: main module version, VCS revision and whether working tree was modified.
+// Returns false and does not set version if build information is not available.
+func (o *Parser) SetVersionFromBuildInfo() bool {
+	info, ok := readBuildInfo()
+	if !ok {
+		return false
+	}
+	o.SetVersion(buildInfoVersion(info))
+	return true
+}
+
+// buildInfoVersion - formats build information as version string, e.g. "v1.2.3 (rev 0123456789ab, modified)"
+func buildInfoVersion(info *debug.BuildInfo) string {
+	result := info.Main.Version
+	if result == "" {
+		result = "(devel)"
+	}
+	var revision string
+	modified := false
+	for _, s := range info.Settings {
+		switch s.Key {
+		case "vcs.revision":
+			revision = s.Value
+		case "vcs.modified":
+			modified = s.Value == "true"
+		}
+	}
+	if len(revision) > 12 {
+		revision = revision[:12]
+	}
+	switch {
+	case revision != "" && modified:
+		result += " (rev " + revision + ", modified)"
+	case revision != "":
+		result += " (rev " + revision + ")"
+	case modified:
+		result += " (modified)"
+	}
+	return result
+}
+
+// EnableVersionCommand adds "version" command, which prints version of the program set with Parser.SetVersion
+// or Parser.SetVersionFromBuildInfo. Returns the added command.
+func (o *Parser) EnableVersionCommand() *Command {
+	c := o.NewCommand("version", "Print version information")
+	c.action = func(c *Command, args *[]string) error {
+		c.printVersion()
+		return nil
+	}
+	c.builtin = "version"
+	return c
+}
+
+// printVersion - prints version of the program and exits if Command exits on help
+func (o *Command) printVersion() {
+	root := o.root()
+	o.printMessage(root.name + " " + root.version)
+	if o.exitOnHelp {
+		exit(0)
+	}
+}